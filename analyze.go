@@ -0,0 +1,444 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// MaxAnalyzedSources limits the number of behavior sources AnalyzeConditions enumerates, since the
+// number of source combinations doubles with every source
+const MaxAnalyzedSources = 16
+
+// Condition analysis rule IDs
+const (
+	RuleAmbiguousCondition   = "ambiguous-condition"
+	RuleUnreachableCondition = "unreachable-condition"
+	RuleConflictingPinState  = "conflicting-pin-state"
+)
+
+// SourceCombination assigns a state to every behavior source, in the order of Behavior.Sources
+type SourceCombination []SourceEvent
+
+func (c SourceCombination) String() string {
+	states := make([]string, len(c))
+	for i, state := range c {
+		states[i] = state.String()
+	}
+	return strings.Join(states, ", ")
+}
+
+// CombinationOutcome is the condition that fires when a source combination is entered through an
+// event of one of its sources
+type CombinationOutcome struct {
+	// Event is the last source event, the one leading into the combination
+	Event SourceEvent `json:"event"`
+
+	// Condition is the name of the condition that fires, or empty if no condition applies and the
+	// pins keep their state
+	Condition string `json:"condition,omitempty"`
+
+	// Failover is set when Condition only fires if the source of the event was the active source,
+	// through the "locked" conditions of the locked source with the highest priority
+	Failover bool `json:"failover,omitempty"`
+
+	condition int
+}
+
+// CombinationAnalysis describes which conditions can fire in a source combination
+type CombinationAnalysis struct {
+	// States is the source combination
+	States SourceCombination `json:"states"`
+
+	// Outcomes are the outcomes of entering the combination through an event of each source
+	Outcomes []CombinationOutcome `json:"outcomes"`
+
+	// Conditions are the names of the distinct conditions that can fire, in the order they are listed.
+	// If there are several, the winner depends on which source changed last.
+	Conditions []string `json:"conditions,omitempty"`
+
+	conditions []int
+}
+
+// Ambiguous reports whether more than one condition can fire in the combination
+func (ca *CombinationAnalysis) Ambiguous() bool {
+	return len(ca.conditions) > 1
+}
+
+// ConditionAnalysis is the result of enumerating all source combinations of a behavior section
+type ConditionAnalysis struct {
+	// Sources are the names of the behavior sources in priority order
+	Sources []string `json:"sources"`
+
+	// Combinations are all combinations of locked and lost sources, starting with all sources lost
+	Combinations []CombinationAnalysis `json:"combinations"`
+
+	// Unreachable are the names of the conditions that fire in no combination
+	Unreachable []string `json:"unreachable,omitempty"`
+
+	// Findings are the ambiguous winners, unreachable conditions and conflicting pin states
+	Findings ValidationErrors `json:"findings,omitempty"`
+}
+
+// Ambiguous returns the number of combinations in which more than one condition can fire
+func (a *ConditionAnalysis) Ambiguous() int {
+	count := 0
+	for i := range a.Combinations {
+		if a.Combinations[i].Ambiguous() {
+			count++
+		}
+	}
+	return count
+}
+
+// AnalyzeConditions enumerates every combination of locked and lost behavior sources of a merged
+// clock chain and determines, for the event of each source leading into the combination, which
+// condition fires under the semantics implemented by Engine. It reports combinations in which the
+// winning condition depends on the order of events, conditions that can never fire, and pins that
+// conditions leave in contradictory states.
+func AnalyzeConditions(cc *ClockChain) (*ConditionAnalysis, error) {
+	e, err := NewEngine(cc)
+	if err != nil {
+		return nil, err
+	}
+	names := e.SourceNames()
+	if len(names) > MaxAnalyzedSources {
+		return nil, fmt.Errorf("behavior has %d sources, at most %d can be analyzed", len(names), MaxAnalyzedSources)
+	}
+
+	conditions := cc.Behavior.Conditions
+	index := make(map[*Condition]int, len(conditions))
+	for i := range conditions {
+		index[&conditions[i]] = i
+	}
+	fired := make([]bool, len(conditions))
+	held := make([]bool, len(conditions))
+	shadowedBy := make([]map[int]bool, len(conditions))
+
+	analysis := &ConditionAnalysis{Sources: names}
+	var vc validationCollector
+	for combination := 0; combination < 1<<len(names); combination++ {
+		states := make(SourceCombination, len(names))
+		for i, name := range names {
+			// The first source is the most significant bit, so that the combinations are ordered like a truth table
+			status := SourceLost
+			if combination&(1<<(len(names)-1-i)) != 0 {
+				status = SourceLocked
+			}
+			states[i] = SourceEvent{Source: name, Status: status}
+			e.status[name] = status
+		}
+		for i := range conditions {
+			if e.holds(&conditions[i]) {
+				held[i] = true
+			}
+		}
+
+		result := CombinationAnalysis{States: states}
+		for _, event := range states {
+			outcome := CombinationOutcome{Event: event, condition: -1}
+			candidates := e.matching(event.Source, event.Status)
+			if len(candidates) == 0 && event.Status == SourceLost {
+				if best := e.bestLocked(); best != "" {
+					candidates = e.matching(best, SourceLocked)
+					outcome.Failover = len(candidates) > 0
+				}
+			}
+			for k, candidate := range candidates {
+				i := index[candidate]
+				if k == 0 {
+					fired[i] = true
+					outcome.condition = i
+					outcome.Condition = candidate.Name
+					continue
+				}
+				if shadowedBy[i] == nil {
+					shadowedBy[i] = make(map[int]bool)
+				}
+				shadowedBy[i][index[candidates[0]]] = true
+			}
+			result.Outcomes = append(result.Outcomes, outcome)
+			if outcome.condition >= 0 && !containsInt(result.conditions, outcome.condition) {
+				result.conditions = append(result.conditions, outcome.condition)
+			}
+		}
+		sort.Ints(result.conditions)
+		for _, i := range result.conditions {
+			result.Conditions = append(result.Conditions, conditions[i].Name)
+		}
+		analysis.Combinations = append(analysis.Combinations, result)
+	}
+
+	reported := make(map[string]bool)
+	for i := range analysis.Combinations {
+		if analysis.Combinations[i].Ambiguous() {
+			analyzeAmbiguity(&vc, cc, &analysis.Combinations[i], reported)
+		}
+	}
+	for i := range conditions {
+		condition := &conditions[i]
+		if !analyzable(e, condition) || fired[i] {
+			continue
+		}
+		analysis.Unreachable = append(analysis.Unreachable, condition.Name)
+		path := cc.conditionPath(i)
+		switch {
+		case !held[i]:
+			vc.warn(RuleUnreachableCondition, path, "condition %s can never fire: its source states contradict each other", condition.Name)
+		case len(shadowedBy[i]) > 0:
+			var earlier []int
+			for j := range shadowedBy[i] {
+				earlier = append(earlier, j)
+			}
+			sort.Ints(earlier)
+			vc.warn(RuleUnreachableCondition, path, "condition %s can never fire: whenever it matches, %s listed before it fires instead",
+				condition.Name, quotedConditionNames(conditions, earlier))
+		default:
+			vc.warn(RuleUnreachableCondition, path, "condition %s can never fire: a source with higher priority is locked whenever it matches", condition.Name)
+		}
+	}
+	for i := range conditions {
+		analyzeDesiredStates(&vc, &conditions[i], cc.conditionPath(i))
+	}
+	analysis.Findings = vc.errs
+	return analysis, nil
+}
+
+// analyzable reports whether a condition is fired by source events: it is neither a "default" nor an
+// "init" condition, its trigger is a source state, and it only mentions known sources with a locked or
+// lost state, also within expressions. Other conditions are reported by validation.
+func analyzable(e *Engine, condition *Condition) bool {
+	if len(condition.Sources) == 0 || condition.Sources[0].IsExpression() {
+		return false
+	}
+	for i := range condition.Sources {
+		for _, state := range condition.Sources[i].leaves() {
+			if _, known := e.sourceIndex[state.SourceName]; !known {
+				return false
+			}
+			if state.ConditionType != ConditionTypeLocked && state.ConditionType != ConditionTypeLost {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// analyzeAmbiguity reports a combination in which several conditions can fire, and the pin settings
+// those conditions disagree on. Conflicting settings are reported once per pin, DPLL and set of conditions.
+func analyzeAmbiguity(vc *validationCollector, cc *ClockChain, combination *CombinationAnalysis, reported map[string]bool) {
+	conditions := cc.Behavior.Conditions
+	last := combination.conditions[len(combination.conditions)-1]
+	var winners []string
+	for _, i := range combination.conditions {
+		var events []string
+		for _, outcome := range combination.Outcomes {
+			if outcome.condition != i {
+				continue
+			}
+			event := outcome.Event.String()
+			if outcome.Failover {
+				event += " with failover"
+			}
+			events = append(events, event)
+		}
+		winners = append(winners, fmt.Sprintf("%q (%s)", conditions[i].Name, strings.Join(events, " or ")))
+	}
+	vc.warn(RuleAmbiguousCondition, cc.conditionPath(last),
+		"with %s, the condition that fires depends on which source changed last: %s", combination.States, strings.Join(winners, ", "))
+
+	tables := make(map[int]PinTable, len(combination.conditions))
+	keys := make(PinTable)
+	for _, i := range combination.conditions {
+		tables[i] = make(PinTable)
+		tables[i].Apply(conditions[i].DesiredStates)
+		for key := range tables[i] {
+			keys[key] = PinSettings{}
+		}
+	}
+	for _, key := range keys.Keys() {
+		for _, dpll := range []string{"eec", "pps"} {
+			var involved []int
+			var values []string
+			conflict := false
+			for _, i := range combination.conditions {
+				settings, ok := tables[i][key]
+				if !ok {
+					continue
+				}
+				state := settings.EEC
+				if dpll == "pps" {
+					state = settings.PPS
+				}
+				if state.Priority == nil && state.State == "" {
+					continue
+				}
+				for _, j := range involved {
+					other := tables[j][key].EEC
+					if dpll == "pps" {
+						other = tables[j][key].PPS
+					}
+					conflict = conflict || pinStatesConflict(state, other)
+				}
+				involved = append(involved, i)
+				values = append(values, fmt.Sprintf("%s (%q)", state, conditions[i].Name))
+			}
+			if !conflict {
+				continue
+			}
+			id := fmt.Sprintf("%s/%s/%v", key, dpll, involved)
+			if reported[id] {
+				continue
+			}
+			reported[id] = true
+			i := involved[len(involved)-1]
+			vc.warn(RuleConflictingPinState, desiredStatePath(&conditions[i], cc.conditionPath(i), key),
+				"with %s, pin %s %s is left at %s depending on which source changed last", combination.States, key, dpll, strings.Join(values, " or "))
+		}
+	}
+}
+
+// analyzeDesiredStates reports desired states of a condition that set a pin differently than an
+// earlier desired state of the same condition. Only the last value is applied.
+func analyzeDesiredStates(vc *validationCollector, condition *Condition, path string) {
+	for k, ds := range condition.DesiredStates {
+		key := NewPinKey(ds.ClockID, ds.BoardLabel)
+		for _, earlier := range condition.DesiredStates[:k] {
+			if NewPinKey(earlier.ClockID, earlier.BoardLabel) != key {
+				continue
+			}
+			for _, dpll := range []struct {
+				name          string
+				before, after *PinState
+			}{{"eec", earlier.EEC, ds.EEC}, {"pps", earlier.PPS, ds.PPS}} {
+				if dpll.before != nil && dpll.after != nil && pinStatesConflict(*dpll.before, *dpll.after) {
+					vc.warn(RuleConflictingPinState, pathIndex(path+".desiredStates", k),
+						"condition %s sets pin %s %s to %s and then to %s, only the last value is applied",
+						condition.Name, key, dpll.name, dpll.before, dpll.after)
+				}
+			}
+		}
+	}
+}
+
+// pinStatesConflict reports whether two pin states set the same field to different values
+func pinStatesConflict(a, b PinState) bool {
+	if a.Priority != nil && b.Priority != nil && *a.Priority != *b.Priority {
+		return true
+	}
+	return a.State != "" && b.State != "" && a.State != b.State
+}
+
+// desiredStatePath returns the path of the last desired state of a condition that sets a pin
+func desiredStatePath(condition *Condition, path string, key PinKey) string {
+	for k := len(condition.DesiredStates) - 1; k >= 0; k-- {
+		ds := condition.DesiredStates[k]
+		if NewPinKey(ds.ClockID, ds.BoardLabel) == key {
+			return pathIndex(path+".desiredStates", k)
+		}
+	}
+	return path
+}
+
+// quotedConditionNames joins the quoted names of conditions by index
+func quotedConditionNames(conditions []Condition, indexes []int) string {
+	names := make([]string, len(indexes))
+	for k, i := range indexes {
+		names[k] = fmt.Sprintf("%q", conditions[i].Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func containsInt(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Analyze runs the condition analysis on a loaded configuration and adds its findings to the
+// findings and suppressed findings of the configuration. The findings of the returned analysis
+// are the ones that are not suppressed.
+func (lc *LoadedConfig) Analyze() (*ConditionAnalysis, error) {
+	analysis, err := AnalyzeConditions(lc.Config)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Findings.Locate(lc.Root)
+	kept, suppressed := analysis.Findings.Suppress(lc.Root, lc.Config.Lint)
+	analysis.Findings = kept
+	lc.Findings = append(lc.Findings, kept...)
+	lc.Suppressed = append(lc.Suppressed, suppressed...)
+	lc.Findings.Sort()
+	return analysis, nil
+}
+
+// runAnalyze implements the analyze subcommand. It reports validation and analysis findings together
+// and exits with 1 if an analysis finding is at least as serious as --fail-on, warning by default,
+// or if validation found errors. Validation warnings are reported but do not fail the analysis.
+func runAnalyze(args []string) int {
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	format := flags.String("format", string(PlanFormatTable), "output format: table or json")
+	report := (&ReportOptions{FailOn: SeverityWarning, MinSeverity: SeverityWarning}).register(flags)
+	addLoadFlags(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser analyze [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+	if *format != string(PlanFormatTable) && *format != string(PlanFormatJSON) {
+		fmt.Fprintf(os.Stderr, "Error: unknown analysis format %q (supported: %s, %s)\n", *format, PlanFormatTable, PlanFormatJSON)
+		return 2
+	}
+
+	loaded, err := LoadConfig(flags.Arg(0), io.Discard)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	analysis, err := loaded.Analyze()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if PlanFormat(*format) == PlanFormatJSON {
+		data, err := json.MarshalIndent(analysis, "", "  ")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Println(string(data))
+		loaded.reportFindings(os.Stderr, report)
+		if analysisFailed(loaded, analysis, report) {
+			return 1
+		}
+		return 0
+	}
+
+	loaded.reportFindings(os.Stdout, report)
+	fmt.Printf("%s: %d source(s), %d combination(s), %d ambiguous, %d unreachable condition(s)\n",
+		loaded.Path, len(analysis.Sources), len(analysis.Combinations), analysis.Ambiguous(), len(analysis.Unreachable))
+	if analysisFailed(loaded, analysis, report) {
+		return 1
+	}
+	return 0
+}
+
+// analysisFailed reports whether the analyze command fails: --fail-on applies to the findings of
+// the analysis, while validation findings only fail it if they are errors
+func analysisFailed(lc *LoadedConfig, analysis *ConditionAnalysis, opts *ReportOptions) bool {
+	return lc.Findings.HasErrors() || analysis.Findings.HasSeverity(opts.FailOn)
+}
@@ -1,6 +1,12 @@
 package main
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -60,7 +66,7 @@ structure:
 		t.Errorf("Expected subsystem name 'TestSubsystem', got '%s'", subsystem.Name)
 	}
 
-	if subsystem.DPLL.ClockID != "0x123456789abcdef0" {
+	if subsystem.DPLL.ClockID != 0x123456789abcdef0 {
 		t.Errorf("Expected clock ID '0x123456789abcdef0', got '%s'", subsystem.DPLL.ClockID)
 	}
 }
@@ -276,3 +282,2670 @@ func testExampleFile(t *testing.T, filePath string) {
 
 	t.Logf("✅ All tests passed for %s", fileName)
 }
+
+// TestSchemaValidation tests that documents are checked against the ptp-hw.yaml OpenAPI schema
+func TestSchemaValidation(t *testing.T) {
+	schema, err := DefaultSchema()
+	if err != nil {
+		t.Fatalf("Failed to load schema: %v", err)
+	}
+
+	testConfig := `
+structure:
+- name: SchemaTestSubsystem
+  ethernet:
+  - ports: ["eth0"]
+  dpll:
+    clockId: "0x123"
+    phaseInputs:
+      REF0:
+        frequency: 1
+        esyncConfigName: esync1
+      REF1:
+        connector: "SMA 1"
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x123"
+    sourceType: gps
+    boardLabel: REF0
+  conditions:
+  - name: "Init"
+    sources:
+    - sourceName: GNSS
+      conditionType: init
+    desiredStates: []
+  - name: "Typo"
+    sources:
+    - sourceName: GNSS
+      conditionType: lockd
+    desiredStates: []
+  - name: "Empty"
+    sources:
+    - {}
+    desiredStates: []
+  - name: "Mixed"
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+      any:
+      - sourceName: GNSS
+        conditionType: locked
+    desiredStates: []
+`
+
+	// Unknown enum values are rejected when decoding, so check the schema on the node tree alone
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(testConfig), &root); err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	violations := schema.ValidateDocument(&root)
+	expected := map[string]bool{
+		"structure[0].dpll.phaseInputs.REF0":              false, // frequency and esyncConfigName together
+		"structure[0].dpll.phaseInputs.REF1.connector":    false, // pattern
+		"behavior.sources[0].sourceType":                  false, // enum
+		"behavior.conditions[1].sources[0].conditionType": false, // enum
+		"behavior.conditions[2].sources[0]":               false, // neither a source state nor an expression
+		"behavior.conditions[3].sources[0]":               false, // both a source state and an expression
+	}
+	for _, violation := range violations {
+		t.Logf("   %v", violation)
+		if _, ok := expected[violation.Path]; !ok {
+			t.Errorf("Unexpected schema violation: %v", violation)
+			continue
+		}
+		expected[violation.Path] = true
+	}
+	for path, found := range expected {
+		if !found {
+			t.Errorf("Expected a schema violation at %s", path)
+		}
+	}
+
+	// A schema override that cannot be loaded fails instead of skipping schema validation
+	schemaFile := SchemaFile
+	SchemaFile = filepath.Join(t.TempDir(), "missing.yaml")
+	t.Cleanup(func() { SchemaFile = schemaFile })
+	if _, err := LoadConfig("examples/dual-wpc.yaml", io.Discard); err == nil || !strings.Contains(err.Error(), "loading schema") {
+		t.Errorf("Expected a schema loading error, got %v", err)
+	}
+}
+
+// TestValidationErrorsAggregated tests that validation reports every problem with its path and source position
+func TestValidationErrorsAggregated(t *testing.T) {
+	testConfig := `
+commonDefinitions:
+  eSyncDefinitions:
+  - name: esync1
+    esyncConfig:
+      transferFrequency: 10000000
+structure:
+- name: First
+  ethernet:
+  - ports: ["eth0"]
+  dpll:
+    clockId: "0x123"
+- name: Second
+  ethernet:
+  - ports: ["eth1"]
+  dpll:
+    clockId: "0x456"
+    phaseInputs:
+      SMA1:
+        esyncConfigName: missing
+    phaseOutputs:
+      "REF-SMA2/U.FL2":
+        frequency: 1
+        esyncConfigName: esync1
+behavior:
+  sources:
+  - name: PTP
+    clockId: "0x123"
+    sourceType: ptpTimeReceiver
+    boardLabel: REF0
+`
+
+	config, root, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	err = config.Validate()
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected ValidationErrors, got %v", err)
+	}
+	errs.Locate(root)
+
+	expected := []ValidationError{
+		{Rule: RuleESyncReference, Path: "structure[1].dpll.phaseInputs.SMA1.esyncConfigName", Line: 20, Column: 26},
+		{Rule: RulePinConfig, Path: `structure[1].dpll.phaseOutputs["REF-SMA2/U.FL2"]`, Line: 23, Column: 9},
+		{Rule: RulePTPTimeReceivers, Path: "behavior.sources[0]", Line: 27, Column: 5},
+		{Rule: RuleUnknownPin, Path: "behavior.sources[0].boardLabel", Line: 30, Column: 17},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d:\n%v", len(expected), len(errs), errs)
+	}
+	for i, want := range expected {
+		got := errs[i]
+		if got.Severity != SeverityError || got.Rule != want.Rule || got.Path != want.Path ||
+			got.Line != want.Line || got.Column != want.Column {
+			t.Errorf("Finding %d: expected %s at %s (%d:%d), got %v", i, want.Rule, want.Path, want.Line, want.Column, got)
+		}
+	}
+}
+
+// TestPinReferenceValidation tests that sources and desired states must target existing pins
+func TestPinReferenceValidation(t *testing.T) {
+	pm, err := NewPluginManager("plugins")
+	if err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+
+	testConfig := `
+structure:
+- name: Leader
+  hardwarePlugin: e810
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      CVL_SDP22:
+        frequency: 1
+    phaseOutputs:
+      REF-SMA1:
+        frequency: 1
+behavior:
+  sources:
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP32
+    ptpTimeReceivers: ["ens4f0"]
+  - name: GNSS
+    clockId: "0x99"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  conditions:
+  - name: "PTP Active"
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        state: connected
+    - clockId: "0x112233fffe445566"
+      boardLabel: REF-SMA1
+      pps:
+        priority: 0
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 0
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	var errs ValidationErrors
+	if !errors.As(config.ValidateWithPlugins(pm), &errs) {
+		t.Fatal("Expected validation errors")
+	}
+
+	expected := map[string]string{
+		"behavior.sources[0].boardLabel":                       RuleUnknownPin,
+		"behavior.sources[1].clockId":                          RuleUnknownClockID,
+		"behavior.conditions[0].desiredStates[0].pps.state":    RulePinDirection,
+		"behavior.conditions[0].desiredStates[1].pps.priority": RulePinDirection,
+	}
+	for _, e := range errs {
+		if rule, ok := expected[e.Path]; !ok || rule != e.Rule {
+			t.Errorf("Unexpected finding: %v", e)
+			continue
+		}
+		delete(expected, e.Path)
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected %s finding at %s", rule, path)
+	}
+
+	// The generated default condition is listed first, without shifting the paths of user conditions
+	if err := pm.MergeUserConfigWithDefaults(config); err != nil {
+		t.Fatalf("Failed to merge plugin defaults: %v", err)
+	}
+	if !isDefaultCondition(&config.Behavior.Conditions[0]) {
+		t.Errorf("Expected the generated default condition first, got %s", config.Behavior.Conditions[0].Name)
+	}
+	if path := config.conditionPath(0); path != `behavior.conditions["Default Configuration (Auto-generated)"]` {
+		t.Errorf("Expected the generated condition to be identified by name, got %s", path)
+	}
+	mapped := map[string]bool{
+		"behavior.conditions[0].desiredStates[0].pps.state":    false,
+		"behavior.conditions[0].desiredStates[1].pps.priority": false,
+	}
+	for _, e := range config.Findings(pm) {
+		if _, ok := mapped[e.Path]; ok {
+			mapped[e.Path] = true
+		}
+	}
+	for path, found := range mapped {
+		if !found {
+			t.Errorf("Expected the merged configuration to report %s at its source path", path)
+		}
+	}
+}
+
+// TestPTPTimeReceiverPorts tests that PTP time receivers must be Ethernet ports of the source subsystem
+func TestPTPTimeReceiverPorts(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  ethernet:
+  - ports: ["ens4f0", "ens4f1"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      CVL_SDP22:
+        frequency: 1
+- name: Follower
+  ethernet:
+  - ports: ["ens7f0"]
+  - ports: ["ens4f1"]
+  dpll:
+    clockId: "0xc7cc7cfffe001122"
+behavior:
+  sources:
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0", "ens7f0", "ens9f0"]
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	var errs ValidationErrors
+	if !errors.As(config.Validate(), &errs) {
+		t.Fatal("Expected validation errors")
+	}
+
+	expected := map[string]string{
+		"structure[1].ethernet[1].ports[0]":       RuleEthernetPort,
+		"behavior.sources[0].ptpTimeReceivers[1]": RuleReceiverPort,
+		"behavior.sources[0].ptpTimeReceivers[2]": RuleReceiverPort,
+	}
+	for _, e := range errs {
+		if rule, ok := expected[e.Path]; !ok || rule != e.Rule {
+			t.Errorf("Unexpected finding: %v", e)
+			continue
+		}
+		t.Logf("   %v", e)
+		delete(expected, e.Path)
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected %s finding at %s", rule, path)
+	}
+}
+
+// TestHardwareCapabilityValidation tests that pin configs are checked against plugin pin capabilities
+func TestHardwareCapabilityValidation(t *testing.T) {
+	pm, err := NewPluginManager("plugins")
+	if err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+
+	testConfig := `
+commonDefinitions:
+  eSyncDefinitions:
+  - name: esync-25MHz
+    esyncConfig:
+      transferFrequency: 25000000
+structure:
+- name: Leader
+  hardwarePlugin: e810
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 25000000
+      CVL_SDP22:
+        esyncConfigName: esync-25MHz
+      SMA1:
+        esyncConfigName: esync-25MHz
+        connector: SMA2
+        phaseAdjustment:
+          internal: 900000
+          external: 200000
+    phaseOutputs:
+      CVL_SDP20:
+        frequency: 1
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	var errs ValidationErrors
+	if !errors.As(config.ValidateWithPlugins(pm), &errs) {
+		t.Fatal("Expected validation errors")
+	}
+
+	expected := map[string]string{
+		"structure[0].dpll.phaseInputs.GNSS_1PPS.frequency":       RuleHWFrequency,
+		"structure[0].dpll.phaseInputs.CVL_SDP22.esyncConfigName": RuleHWESync,
+		"structure[0].dpll.phaseInputs.SMA1.esyncConfigName":      RuleHWFrequency,
+		"structure[0].dpll.phaseInputs.SMA1.connector":            RuleHWConnector,
+		"structure[0].dpll.phaseInputs.SMA1.phaseAdjustment":      RuleHWPhaseAdjust,
+		"structure[0].dpll.phaseOutputs.CVL_SDP20":                RuleHWDirection,
+		"structure[0].dpll.phaseInputs.SMA1":                      RuleUndrivenInput,
+	}
+	for _, e := range errs {
+		if rule, ok := expected[e.Path]; !ok || rule != e.Rule {
+			t.Errorf("Unexpected finding: %v", e)
+			continue
+		}
+		t.Logf("   %v", e)
+		delete(expected, e.Path)
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected %s finding at %s", rule, path)
+	}
+
+	// Plugin defaults are checked against the plugin pin capabilities when the plugin is loaded
+	pluginsDir := t.TempDir()
+	plugin := `
+pluginInfo:
+  name: direction-test
+pins:
+  OUT1:
+    direction: output
+    kind: phase
+specificDefaults:
+  OUT1:
+    pps:
+      priority: 0
+`
+	if err := os.WriteFile(filepath.Join(pluginsDir, "direction-test.yaml"), []byte(plugin), 0o644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	if _, err := NewPluginManager(pluginsDir); err == nil || !strings.Contains(err.Error(), "specificDefaults: pin OUT1 pps: priority can only be set on input pins") {
+		t.Errorf("Expected the priority default of an output pin to be rejected, got %v", err)
+	} else {
+		t.Logf("✅ %v", err)
+	}
+}
+
+// TestTopologyValidation tests link inference, signal checks across links and timing loop detection
+func TestTopologyValidation(t *testing.T) {
+	testConfig := `
+commonDefinitions:
+  eSyncDefinitions:
+  - name: esync1
+    esyncConfig:
+      transferFrequency: 10000000
+structure:
+- name: A
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      REF1:
+        connector: C2
+        esyncConfigName: esync1
+    phaseOutputs:
+      OUT1:
+        connector: C1
+        esyncConfigName: esync1
+      OUT3:
+        connector: C9
+    frequencyOutputs:
+      OUT2:
+        connector: C3
+        frequency: 10000000
+- name: B
+  dpll:
+    clockId: "0xc7cc7cfffe001122"
+    phaseInputs:
+      REF1:
+        connector: C1
+        esyncConfigName: esync1
+      REF3:
+        frequency: 1
+    phaseOutputs:
+      OUT1:
+        connector: C2
+        esyncConfigName: esync1
+    frequencyInputs:
+      REF2:
+        connector: C3
+        frequency: 25000000
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0xc7cc7cfffe001122"
+    sourceType: gnss
+    boardLabel: REF3
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: REF1
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: OUT2
+      eec:
+        state: disconnected
+      pps:
+        state: disconnected
+  - name: Follow B
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: REF1
+      pps:
+        priority: 1
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	pins := NewPinIndex(config, nil)
+	topology, errs := BuildTopology(config, pins)
+	if len(errs) != 0 {
+		t.Fatalf("Unexpected topology findings: %v", errs)
+	}
+	if len(topology.Links) != 3 {
+		t.Fatalf("Expected 3 inferred links, got %d", len(topology.Links))
+	}
+	for _, link := range topology.Links {
+		t.Logf("🔗 %s (%s)", link.String(), link.Description)
+	}
+
+	if !errors.As(config.Validate(), &errs) {
+		t.Fatal("Expected validation errors")
+	}
+
+	expected := map[string]string{
+		"structure[0].dpll.phaseOutputs.OUT1":     RuleTopologyLoop,
+		"structure[0].dpll.phaseOutputs.OUT3":     RuleDanglingOutput,
+		"structure[0].dpll.frequencyOutputs.OUT2": RuleLinkSignal,
+	}
+	for _, e := range errs {
+		if rule, ok := expected[e.Path]; !ok || rule != e.Rule {
+			t.Errorf("Unexpected finding: %v", e)
+			continue
+		}
+		if e.Rule == RuleTopologyLoop && (!strings.Contains(e.Message, `"Follow B"`) || strings.Contains(e.Message, `"default"`)) {
+			t.Errorf("Expected the loop to be active only in condition Follow B: %v", e)
+		}
+		t.Logf("   %v", e)
+		delete(expected, e.Path)
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected %s finding at %s", rule, path)
+	}
+
+	// Explicit links replace inference and must run from an output to an input
+	config.Links = []Link{
+		{From: LinkEnd{ClockID: 0x112233fffe445566, BoardLabel: "REF1"}, To: LinkEnd{ClockID: 0xc7cc7cfffe001122, BoardLabel: "REF1"}},
+		{From: LinkEnd{External: "GNSS"}, To: LinkEnd{External: "Analyzer"}},
+	}
+	_, errs = BuildTopology(config, pins)
+	if len(errs) != 2 || errs[0].Rule != RuleLinkDirection || errs[1].Rule != RuleLinkEndpoint {
+		t.Fatalf("Expected link-direction and link-endpoint findings, got: %v", errs)
+	}
+	t.Logf("✅ %d explicit link findings", len(errs))
+}
+
+// TestGraphRendering tests DOT and Mermaid export of subsystems, pins, links and condition highlights
+func TestGraphRendering(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+    phaseOutputs:
+      "REF-SMA2/U.FL2":
+        connector: SMA2
+        frequency: 1
+- name: Follower
+  dpll:
+    clockId: "0xc7cc7cfffe001122"
+    phaseInputs:
+      SMA1:
+        connector: SMA1
+        frequency: 1
+links:
+- name: cable 1
+  from:
+    clockId: "0x112233fffe445566"
+    boardLabel: "REF-SMA2/U.FL2"
+  to:
+    clockId: "0xc7cc7cfffe001122"
+    boardLabel: SMA1
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  conditions:
+  - name: GNSS Lost
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: "REF-SMA2/U.FL2"
+      pps:
+        state: connected
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	dot, err := RenderGraph(config, nil, GraphOptions{Condition: "GNSS Lost"})
+	if err != nil {
+		t.Fatalf("DOT rendering failed: %v", err)
+	}
+	for _, want := range []string{
+		`subgraph cluster_s0 {`,
+		`label="Leader\n0x112233fffe445566";`,
+		`s0p0 [label="{<pin> GNSS_1PPS\n1PPS\nsource GNSS (gnss)}", style="filled,dashed", fillcolor=lightcoral];`,
+		`s0p1 [label="{<pin> REF-SMA2/U.FL2\n1PPS|<conn> SMA2}", style=filled, fillcolor=palegreen];`,
+		`s1p0 [label="{<conn> SMA1|<pin> SMA1\n1PPS}"];`,
+		`s0p1:conn -> s1p0:conn [label="cable 1"];`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("DOT output is missing %s:\n%s", want, dot)
+		}
+	}
+	t.Logf("✅ DOT: %d lines", strings.Count(dot, "\n"))
+
+	mermaid, err := RenderGraph(config, nil, GraphOptions{Format: GraphFormatMermaid, Condition: "GNSS Lost"})
+	if err != nil {
+		t.Fatalf("Mermaid rendering failed: %v", err)
+	}
+	for _, want := range []string{
+		`flowchart LR`,
+		`subgraph s1["Follower<br/>0xc7cc7cfffe001122"]`,
+		`s1p0["SMA1<br/>1PPS<br/>[SMA1]"]`,
+		`s0p1 -->|"cable 1"| s1p0`,
+		`class s0p1 enabled`,
+		`class s0p0 disabled`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("Mermaid output is missing %s:\n%s", want, mermaid)
+		}
+	}
+	t.Logf("✅ Mermaid: %d lines", strings.Count(mermaid, "\n"))
+
+	if _, err := RenderGraph(config, nil, GraphOptions{Condition: "missing"}); err == nil {
+		t.Error("Expected an error for an unknown condition")
+	}
+	if _, err := RenderGraph(config, nil, GraphOptions{Format: "svg"}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+// TestConditionEngine tests condition triggering, source priority, failover and holdover
+func TestConditionEngine(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+      CVL_SDP22:
+        frequency: 1
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0"]
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+  - name: GNSS Active
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 0
+  - name: PTP Active
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 1
+  - name: GNSS Lost, PTP Lost
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    - sourceName: PTP
+      conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+  - name: PTP Lost
+    sources:
+    - sourceName: PTP
+      conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 255
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	engine, err := NewEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+
+	gnss := PinKey{ClockID: 0x112233fffe445566, BoardLabel: "GNSS_1PPS"}
+	ptp := PinKey{ClockID: 0x112233fffe445566, BoardLabel: "CVL_SDP22"}
+	if engine.Table().InputEnabled(gnss) || engine.Table().InputEnabled(ptp) {
+		t.Fatal("Expected the default condition to disable both inputs")
+	}
+
+	steps := []struct {
+		event     SourceEvent
+		condition string
+		active    string
+		failover  bool
+		holdover  bool
+		changes   int
+	}{
+		{SourceEvent{"PTP", SourceLocked}, "PTP Active", "PTP", false, false, 1},
+		{SourceEvent{"GNSS", SourceLocked}, "GNSS Active", "GNSS", false, false, 1},
+		// GNSS has priority, so PTP conditions do not fire while it is locked
+		{SourceEvent{"PTP", SourceLost}, "", "GNSS", false, false, 0},
+		{SourceEvent{"PTP", SourceLocked}, "", "GNSS", false, false, 0},
+		{SourceEvent{"PTP", SourceLocked}, "", "GNSS", false, false, 0},
+		// No condition handles GNSS lost while PTP is locked, so PTP takes over
+		{SourceEvent{"GNSS", SourceLost}, "PTP Active", "PTP", true, false, 0},
+		{SourceEvent{"PTP", SourceLost}, "PTP Lost", "", false, true, 1},
+	}
+	for i, expected := range steps {
+		step, err := engine.Handle(expected.event)
+		if err != nil {
+			t.Fatalf("Step %d: %v", i, err)
+		}
+		name := ""
+		if step.Condition != nil {
+			name = step.Condition.Name
+		}
+		if name != expected.condition || step.ActiveSource != expected.active || step.Failover != expected.failover ||
+			step.Holdover != expected.holdover || len(step.Changes) != expected.changes {
+			t.Errorf("Step %d (%v): got condition %q, active %q, failover %v, holdover %v, changes %v",
+				i, expected.event, name, step.ActiveSource, step.Failover, step.Holdover, step.Changes)
+			continue
+		}
+		t.Logf("⚡ %v -> %q (active source %q, %d change(s))", expected.event, name, step.ActiveSource, len(step.Changes))
+	}
+
+	if engine.Table().InputEnabled(ptp) || !engine.Table().InputEnabled(gnss) {
+		t.Errorf("Unexpected final pin table: %v", engine.Table())
+	}
+
+	if _, err := engine.Handle(SourceEvent{"Ethernet", SourceLocked}); err == nil {
+		t.Error("Expected an error for an unknown source")
+	}
+	if _, err := engine.Handle(SourceEvent{"GNSS", "holdover"}); err == nil {
+		t.Error("Expected an error for an invalid status")
+	}
+
+	engine.Reset()
+	if engine.ActiveCondition() != nil || engine.SourceStatus("GNSS") != SourceLost {
+		t.Error("Expected Reset to restore the initial state")
+	}
+}
+
+// TestSimulateScenario tests scenario simulation and expectation checks against an example configuration
+func TestSimulateScenario(t *testing.T) {
+	loaded, err := LoadConfig("examples/bidirectional.yaml", io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	if loaded.Findings.HasErrors() {
+		t.Fatalf("Expected the example to validate, got: %v", loaded.Findings.Filter(SeverityError))
+	}
+	scenario, err := LoadScenario("examples/scenarios/bidirectional-gnss-failover.yaml")
+	if err != nil {
+		t.Fatalf("Failed to load scenario: %v", err)
+	}
+
+	results, err := RunScenario(loaded.Config, scenario)
+	if err != nil {
+		t.Fatalf("Simulation failed: %v", err)
+	}
+	var report strings.Builder
+	if failed := printScenarioResults(&report, results); failed > 0 {
+		t.Fatalf("Expected all expectations to be met:\n%s", report.String())
+	}
+	t.Logf("✅ %d steps simulated:\n%s", len(results), report.String())
+
+	// Expectations that are not met are reported per step
+	wrong := "GNSS Active, Ethernet don't care"
+	scenario.Steps[3].Expect.Condition = &wrong
+	scenario.Steps[3].Expect.Pins[0].PPS.Priority = nil
+	scenario.Steps[3].Expect.Pins[0].PPS.State = "connected"
+	results, err = RunScenario(loaded.Config, scenario)
+	if err != nil {
+		t.Fatalf("Simulation failed: %v", err)
+	}
+	if failures := results[3].Failures; len(failures) != 2 {
+		t.Errorf("Expected 2 failures in step 3, got: %v", failures)
+	}
+
+	if _, err := RunScenario(loaded.Config, &Scenario{Steps: []ScenarioStep{{Event: "GNSS"}}}); err == nil {
+		t.Error("Expected an error for an event without a status")
+	}
+}
+
+// TestDPLLBackend tests mapping pin configs and desired states onto pin-set requests, applied to the fake backend
+func TestDPLLBackend(t *testing.T) {
+	testConfig := `
+commonDefinitions:
+  eSyncDefinitions:
+  - name: esync-10MHz
+    esyncConfig:
+      transferFrequency: 10000000
+structure:
+- name: Leader
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      CVL_SDP22:
+        frequency: 1
+        phaseAdjustment:
+          internal: 1000
+          external: 250
+    phaseOutputs:
+      REF-SMA1:
+        esyncConfigName: esync-10MHz
+        phaseAdjustment:
+          internal: 2000
+          external: 500
+behavior:
+  conditions:
+  - name: PTP Active
+    sources: []
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 255
+      pps:
+        priority: 0
+    - clockId: "0x112233fffe445566"
+      boardLabel: REF-SMA1
+      pps:
+        state: connected
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	fake, err := NewFakeDPLLForConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create fake backend: %v", err)
+	}
+	if err := ApplyCondition(fake, config, &config.Behavior.Conditions[0]); err != nil {
+		t.Fatalf("Failed to apply condition: %v", err)
+	}
+	if len(fake.Requests) != 5 {
+		t.Fatalf("Expected 5 pin-set requests, got %d", len(fake.Requests))
+	}
+	t.Logf("📡 %d pin-set requests applied", len(fake.Requests))
+
+	pins, _ := fake.Pins()
+	devices, _ := fake.Devices()
+	for _, pin := range pins {
+		switch pin.BoardLabel {
+		case "CVL_SDP22":
+			if pin.Frequency != 1 || pin.PhaseAdjust != 1250 {
+				t.Errorf("Unexpected input pin settings: %+v", pin)
+			}
+			for _, parent := range pin.Parents {
+				want := uint32(255)
+				if devices[parent.DeviceID].Type == DPLLTypePPS {
+					want = 0
+				}
+				if parent.Priority == nil || *parent.Priority != want {
+					t.Errorf("Unexpected %s priority of CVL_SDP22: %v", devices[parent.DeviceID].Type, parent.Priority)
+				}
+			}
+		case "REF-SMA1":
+			if pin.Frequency != 10000000 || pin.ESyncFrequency != 1 || pin.PhaseAdjust != 2000 {
+				t.Errorf("Unexpected output pin settings: %+v", pin)
+			}
+			if pin.Parents[1].State != "connected" || pin.Parents[0].State != "disconnected" {
+				t.Errorf("Unexpected output pin states: %+v", pin.Parents)
+			}
+		}
+	}
+
+	// Requests survive the netlink encoding
+	encoded, err := encodeDPLLPinSet(fake.Requests[3])
+	if err != nil {
+		t.Fatalf("Failed to encode pin-set request: %v", err)
+	}
+	decoded, err := decodeDPLLPin(encoded)
+	if err != nil {
+		t.Fatalf("Failed to decode pin-set request: %v", err)
+	}
+	if decoded.ID != fake.Requests[3].PinID || len(decoded.Parents) != 1 ||
+		decoded.Parents[0].DeviceID != *fake.Requests[3].ParentDevice || *decoded.Parents[0].Priority != 0 {
+		t.Errorf("Netlink round trip mismatch: %+v", decoded)
+	}
+
+	// Unknown pins are reported before anything is applied
+	fake.Requests = nil
+	err = ApplyOperations(fake, []PinOperation{
+		{ClockID: 0x112233fffe445566, BoardLabel: "CVL_SDP22", DPLLType: DPLLTypePPS, State: "selectable"},
+		{ClockID: 0x112233fffe445566, BoardLabel: "SMA9", DPLLType: DPLLTypePPS, State: "connected"},
+	})
+	if err == nil || len(fake.Requests) != 0 {
+		t.Errorf("Expected an error without requests for an unknown pin, got %v and %d requests", err, len(fake.Requests))
+	}
+}
+
+// TestPlan tests that plans list pin configurations first, then desired states in the order they are listed
+func TestPlan(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      CVL_SDP22:
+        frequency: 1
+    phaseOutputs:
+      "REF-SMA2/U.FL2":
+        frequency: 1
+        phaseAdjustment:
+          internal: 1212
+behavior:
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: "REF-SMA2/U.FL2"
+      pps:
+        state: disconnected
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 255
+      pps:
+        priority: 0
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	plan, err := BuildPlan(config, "", true)
+	if err != nil {
+		t.Fatalf("Failed to build plan: %v", err)
+	}
+
+	expected := []string{
+		"pin-set clock 0x112233fffe445566 label CVL_SDP22 frequency 1",
+		"pin-set clock 0x112233fffe445566 label REF-SMA2/U.FL2 frequency 1 phase-adjust 1212",
+		"pin-set clock 0x112233fffe445566 label REF-SMA2/U.FL2 dpll-type pps state disconnected",
+		"pin-set clock 0x112233fffe445566 label CVL_SDP22 dpll-type eec prio 255",
+		"pin-set clock 0x112233fffe445566 label CVL_SDP22 dpll-type pps prio 0",
+	}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d", len(expected), len(plan.Steps))
+	}
+	for i, step := range plan.Steps {
+		if step.PinOperation.String() != expected[i] {
+			t.Errorf("Step %d: expected %q, got %q", i+1, expected[i], step.PinOperation.String())
+		}
+	}
+	if plan.Steps[4].Origin != "behavior.conditions[0].desiredStates[1].pps" {
+		t.Errorf("Unexpected origin: %s", plan.Steps[4].Origin)
+	}
+
+	for _, format := range []PlanFormat{PlanFormatTable, PlanFormatJSON, PlanFormatScript} {
+		rendered, err := plan.Format(format)
+		if err != nil {
+			t.Fatalf("Failed to format plan as %s: %v", format, err)
+		}
+		t.Logf("📋 %s: %d bytes", format, len(rendered))
+	}
+	script, _ := plan.Format(PlanFormatScript)
+	if !strings.Contains(script, `pin_set '{"id": '"$(pin_id 1234606422428505446 '"REF-SMA2/U.FL2"')"', "parent-device": {"parent-id": '"$(device_id 1234606422428505446 pps)"', "state": "disconnected"}}'`) {
+		t.Errorf("Unexpected ynl script:\n%s", script)
+	}
+
+	if _, err := BuildPlan(config, "missing", true); err == nil {
+		t.Error("Expected an error for an unknown condition")
+	}
+
+	// Plugin defaults are listed by board label, so that plans of generated conditions are stable
+	loaded, err := LoadConfig("examples/dual-wpc.yaml", io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to load configuration: %v", err)
+	}
+	generated := loaded.Config.Behavior.Conditions[0]
+	if !generated.generated || len(generated.DesiredStates) < 2 {
+		t.Fatalf("Expected a generated default condition with plugin defaults, got %+v", generated)
+	}
+	for i := 1; i < len(generated.DesiredStates); i++ {
+		previous, current := generated.DesiredStates[i-1], generated.DesiredStates[i]
+		if previous.ClockID == current.ClockID && previous.BoardLabel > current.BoardLabel {
+			t.Errorf("Expected plugin defaults sorted by board label, got %s before %s", previous.BoardLabel, current.BoardLabel)
+		}
+	}
+}
+
+// TestTransitionPlan tests that transitions apply break operations before make operations and flag timing loops
+func TestTransitionPlan(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      SMA1:
+        connector: C2
+        frequency: 1
+    phaseOutputs:
+      SMA2:
+        connector: C1
+        frequency: 1
+- name: Follower
+  dpll:
+    clockId: "0xc7cc7cfffe001122"
+    phaseInputs:
+      SMA1:
+        connector: C1
+        frequency: 1
+      GNSS_1PPS:
+        frequency: 1
+    phaseOutputs:
+      SMA2:
+        connector: C2
+        frequency: 1
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0xc7cc7cfffe001122"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  conditions:
+  - name: Leader Active
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: SMA1
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+    - clockId: "0xc7cc7cfffe001122"
+      boardLabel: SMA2
+      eec:
+        state: disconnected
+      pps:
+        state: disconnected
+  - name: Follower Drives Leader
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: "0xc7cc7cfffe001122"
+      boardLabel: SMA2
+      eec:
+        state: connected
+      pps:
+        state: connected
+    - clockId: "0x112233fffe445566"
+      boardLabel: SMA1
+      eec:
+        priority: 0
+      pps:
+        priority: 0
+    - clockId: "0x112233fffe445566"
+      boardLabel: SMA2
+      eec:
+        state: disconnected
+      pps:
+        state: disconnected
+    - clockId: "0xc7cc7cfffe001122"
+      boardLabel: SMA1
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+  - name: Both Driven
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    desiredStates:
+    - clockId: "0xc7cc7cfffe001122"
+      boardLabel: SMA2
+      pps:
+        state: connected
+    - clockId: "0x112233fffe445566"
+      boardLabel: SMA1
+      pps:
+        priority: 1
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+
+	plan, err := BuildTransitionPlan(config, "Leader Active", "Follower Drives Leader")
+	if err != nil {
+		t.Fatalf("Failed to plan transition: %v", err)
+	}
+	expected := []string{
+		"pin-set clock 0x112233fffe445566 label SMA2 dpll-type eec state disconnected",
+		"pin-set clock 0x112233fffe445566 label SMA2 dpll-type pps state disconnected",
+		"pin-set clock 0xc7cc7cfffe001122 label SMA1 dpll-type eec prio 255",
+		"pin-set clock 0xc7cc7cfffe001122 label SMA1 dpll-type pps prio 255",
+		"pin-set clock 0x112233fffe445566 label SMA1 dpll-type eec prio 0",
+		"pin-set clock 0x112233fffe445566 label SMA1 dpll-type pps prio 0",
+		"pin-set clock 0xc7cc7cfffe001122 label SMA2 dpll-type eec state connected",
+		"pin-set clock 0xc7cc7cfffe001122 label SMA2 dpll-type pps state connected",
+	}
+	if len(plan.Steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d:\n%s", len(expected), len(plan.Steps), plan.table())
+	}
+	for i, step := range plan.Steps {
+		if step.PinOperation.String() != expected[i] {
+			t.Errorf("Step %d: expected %q, got %q", i+1, expected[i], step.PinOperation.String())
+		}
+		wantPhase := TransitionMake
+		if i < 4 {
+			wantPhase = TransitionBreak
+		}
+		if step.Phase != wantPhase {
+			t.Errorf("Step %d: expected phase %s, got %s", i+1, wantPhase, step.Phase)
+		}
+	}
+	if plan.Steps[0].Origin != "behavior.conditions[1].desiredStates[2].eec" {
+		t.Errorf("Unexpected origin: %s", plan.Steps[0].Origin)
+	}
+	if plan.Unsafe() {
+		t.Errorf("Expected a safe transition:\n%s", plan.table())
+	}
+	t.Logf("✅ Transition ordered safely: %d operations, break before make", len(plan.Steps))
+
+	// Both Driven enables the follower output while the leader still drives the follower
+	plan, err = BuildTransitionPlan(config, "Leader Active", "Both Driven")
+	if err != nil {
+		t.Fatalf("Failed to plan transition: %v", err)
+	}
+	if len(plan.Steps) != 2 || len(plan.Steps[0].Loops) != 0 || len(plan.Steps[1].Loops) != 1 {
+		t.Fatalf("Expected a timing loop after the last step only:\n%s", plan.table())
+	}
+	if plan.Steps[1].Loops[0] != "Leader/SMA2 -> Follower/SMA1, Follower/SMA2 -> Leader/SMA1" {
+		t.Errorf("Unexpected loop: %s", plan.Steps[1].Loops[0])
+	}
+	t.Logf("⚠️  Unsafe transition flagged: %s", plan.Steps[1].Loops[0])
+
+	if _, err := BuildTransitionPlan(config, "missing", "Both Driven"); err == nil {
+		t.Error("Expected an error for an unknown condition")
+	}
+}
+
+// TestDPLLStatus tests that drift between recorded DPLL state and the expected condition is reported
+func TestDPLLStatus(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      CVL_SDP22:
+        frequency: 1
+    phaseOutputs:
+      REF-SMA1:
+        frequency: 1
+        phaseAdjustment:
+          internal: 2000
+behavior:
+  sources:
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 255
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: REF-SMA1
+      pps:
+        state: connected
+  - name: PTP Active
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 0
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	fake, err := NewFakeDPLLForConfig(config)
+	if err != nil {
+		t.Fatalf("Failed to create fake DPLL: %v", err)
+	}
+	if err := ApplyCondition(fake, config, &config.Behavior.Conditions[1]); err != nil {
+		t.Fatalf("Failed to apply condition: %v", err)
+	}
+	if err := ApplyCondition(fake, config, &config.Behavior.Conditions[0]); err != nil {
+		t.Fatalf("Failed to apply condition: %v", err)
+	}
+
+	fixture := filepath.Join(t.TempDir(), "dpll.json")
+	if err := RecordDPLLFixture(fake, fixture); err != nil {
+		t.Fatalf("Failed to record fixture: %v", err)
+	}
+	recorded, err := LoadDPLLFixture(fixture)
+	if err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+
+	status, err := CheckDrift(recorded, config, "")
+	if err != nil {
+		t.Fatalf("Failed to check drift: %v", err)
+	}
+	if len(status.Drift) != 0 {
+		t.Errorf("Expected no drift from the default condition, got: %v", status.Drift)
+	}
+	if len(status.Devices) != 2 || status.Devices[0].Subsystem != "Leader" {
+		t.Errorf("Expected 2 devices of subsystem Leader, got: %+v", status.Devices)
+	}
+	t.Logf("✅ Recorded state matches condition %q", status.Condition)
+
+	// The hardware is still in the default state, but PTP Active is expected
+	status, err = CheckDrift(recorded, config, "PTP Active")
+	if err != nil {
+		t.Fatalf("Failed to check drift: %v", err)
+	}
+	expected := []string{"0x112233fffe445566:CVL_SDP22 pps prio: expected 0, actual 255"}
+	if len(status.Drift) != len(expected) {
+		t.Fatalf("Expected %d drift(s), got: %v", len(expected), status.Drift)
+	}
+	for i, drift := range status.Drift {
+		if drift.String() != expected[i] {
+			t.Errorf("Expected drift %q, got %q", expected[i], drift.String())
+		}
+	}
+
+	// Drift of pin settings and missing pins
+	pins, _ := recorded.Pins()
+	for i := range pins {
+		if pins[i].BoardLabel == "REF-SMA1" {
+			pins[i].PhaseAdjust = 0
+			pins[i].Parents[1].State = "disconnected"
+		}
+	}
+	devices, _ := recorded.Devices()
+	status, err = CheckDrift(NewFakeDPLL(devices, pins[1:]), config, "")
+	if err != nil {
+		t.Fatalf("Failed to check drift: %v", err)
+	}
+	expected = []string{
+		"0x112233fffe445566:CVL_SDP22 pin: expected present, actual no DPLL pin with clock ID 0x112233fffe445566 and board label CVL_SDP22",
+		"0x112233fffe445566:REF-SMA1 phase-adjust: expected 2000, actual 0",
+		"0x112233fffe445566:REF-SMA1 pps state: expected connected, actual disconnected",
+	}
+	if len(status.Drift) != len(expected) {
+		t.Fatalf("Expected %d drift(s), got: %v", len(expected), status.Drift)
+	}
+	for i, drift := range status.Drift {
+		if drift.String() != expected[i] {
+			t.Errorf("Expected drift %q, got %q", expected[i], drift.String())
+		}
+		t.Logf("⚠️  %s", drift)
+	}
+}
+
+// TestClockIDDiscovery tests that omitted clock IDs are discovered from the first Ethernet port
+func TestClockIDDiscovery(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  dpll:
+    phaseOutputs:
+      REF-SMA1:
+        frequency: 1
+  ethernet:
+  - ports: ["ens4f0", "ens4f1"]
+- name: Follower
+  dpll:
+    phaseInputs:
+      SMA1:
+        frequency: 1
+  ethernet:
+  - ports: ["ens5f0"]
+- name: Unplugged
+  dpll: {}
+  ethernet:
+  - ports: ["ens6f0"]
+- name: Portless
+  dpll: {}
+`
+
+	root := t.TempDir()
+	for iface, mac := range map[string]string{"ens4f0": "11:22:33:44:55:66", "ens4f1": "11:22:33:44:55:67", "ens5f0": "c7:cc:7c:00:11:22"} {
+		dir := filepath.Join(root, "class", "net", iface)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "address"), []byte(mac+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	findings := config.DiscoverClockIDs(&ClockIDDiscovery{SysfsRoot: root})
+
+	expectedIDs := []ClockID{0x112233fffe445566, 0xc7cc7cfffe001122, 0, 0}
+	for i, subsystem := range config.Structure {
+		if subsystem.DPLL.ClockID != expectedIDs[i] {
+			t.Errorf("Subsystem %s: expected clock ID %q, got %q", subsystem.Name, expectedIDs[i], subsystem.DPLL.ClockID)
+		}
+	}
+	expected := map[string]string{
+		"structure[2].dpll": RuleClockIDDiscovery,
+		"structure[3].dpll": RuleClockIDDiscovery,
+	}
+	if len(findings) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(expected), len(findings), findings)
+	}
+	for _, finding := range findings {
+		if expected[finding.Path] != finding.Rule || finding.Severity != SeverityWarning {
+			t.Errorf("Unexpected finding: %v", finding)
+		}
+		t.Logf("⚠️  %v", finding)
+	}
+
+	// The DPLL pin of the port takes precedence over its MAC address
+	fake := NewFakeDPLL(
+		[]DPLLDevice{{ID: 0, ClockID: 0x507c6ffffe1b5d6b, Type: DPLLTypeEEC}},
+		[]DPLLPin{{ID: 7, ClockID: 0x507c6ffffe1b5d6b, BoardLabel: "RCLKA"}})
+	fake.InterfacePins = map[string]uint32{"ens4f0": 7}
+	discovery := &ClockIDDiscovery{SysfsRoot: root, Backend: fake}
+	if clockID, err := discovery.Discover("ens4f0"); err != nil || clockID != 0x507c6ffffe1b5d6b {
+		t.Errorf("Expected clock ID of the DPLL pin, got %q (%v)", clockID, err)
+	}
+	if _, err := discovery.Discover("ens5f0"); err == nil {
+		t.Error("Expected an error for a MAC derived clock ID without a DPLL device")
+	}
+	t.Logf("✅ Clock IDs discovered through sysfs and the DPLL backend")
+
+	// Loading only discovers clock IDs when asked to, and reports omitted ones otherwise
+	path := filepath.Join(t.TempDir(), "discovery.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	sysfsRoot := SysfsRoot
+	SysfsRoot = root
+	t.Cleanup(func() { SysfsRoot = sysfsRoot })
+	for _, enabled := range []bool{false, true} {
+		DiscoverClockIDs = enabled
+		loaded, err := LoadConfig(path, io.Discard)
+		DiscoverClockIDs = false
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		discovered := loaded.Config.Structure[0].DPLL.ClockID != 0
+		if discovered != enabled {
+			t.Errorf("Discovery enabled %v: expected the clock ID of Leader to be discovered %v, got %q",
+				enabled, enabled, loaded.Config.Structure[0].DPLL.ClockID)
+		}
+		omitted := 0
+		for _, finding := range loaded.Findings {
+			if finding.Rule == RuleClockIDDiscovery && finding.Severity == SeverityInfo {
+				omitted++
+			}
+		}
+		if expected := map[bool]int{false: 4, true: 0}[enabled]; omitted != expected {
+			t.Errorf("Discovery enabled %v: expected %d omitted clock ID findings, got %d", enabled, expected, omitted)
+		}
+	}
+
+	// Routing netlink replies carrying the DPLL pin of an interface
+	var pin, link netlinkEncoder
+	pin.uint32(dpllAttrPinID, 7)
+	link.string(iflaIfname, "ens4f0")
+	link.bytes(iflaDPLLPin|nlaFlagNested, pin.buf)
+	if pinID, ok, err := decodeLinkDPLLPin(append(make([]byte, ifinfomsgLen), link.buf...)); err != nil || !ok || pinID != 7 {
+		t.Errorf("Expected DPLL pin 7, got %d, %v, %v", pinID, ok, err)
+	}
+}
+
+// TestClockIdentifierDerivation tests that clock identifiers derive clock IDs from MAC addresses and interfaces
+func TestClockIdentifierDerivation(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "class", "net", "ens5f0")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "address"), []byte("c7:cc:7c:00:11:22\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sysfsRoot := SysfsRoot
+	SysfsRoot = root
+	t.Cleanup(func() { SysfsRoot = sysfsRoot })
+
+	testConfig := `
+commonDefinitions:
+  clockIdentifiers:
+  - alias: Leader
+    mac: "11:22:33:44:55:66"
+  - alias: Follower
+    interface: ens5f0
+  - alias: Spare
+    clockId: "5799633565432596414"
+structure:
+- name: Leader
+  dpll:
+    clockId: Leader
+- name: Follower
+  dpll:
+    clockId: Follower
+- name: Spare
+  dpll:
+    clockId: Spare
+`
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	expected := []ClockID{0x112233fffe445566, 0xc7cc7cfffe001122, 0x507c6fffff0ac7be}
+	for i, subsystem := range config.Structure {
+		if subsystem.DPLL.ClockID != expected[i] {
+			t.Errorf("Subsystem %s: expected clock ID %s, got %s", subsystem.Name, expected[i], subsystem.DPLL.ClockID)
+		}
+	}
+	t.Logf("✅ Clock IDs derived from MAC address and interface")
+
+	// The schema applies to the clock IDs the aliases stand for, so aliases without digits are accepted
+	path := filepath.Join(t.TempDir(), "aliases.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	loaded, err := LoadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	for _, finding := range loaded.Findings {
+		if finding.Rule == RuleSchema {
+			t.Errorf("Unexpected schema finding for an alias: %v", finding)
+		}
+	}
+
+	invalid := map[string]ClockIdentifier{
+		"clockId and mac":   {Alias: "A", ClockID: 0x112233fffe445566, MAC: "11:22:33:44:55:66"},
+		"none":              {Alias: "A"},
+		"invalid mac":       {Alias: "A", MAC: "11:22:33:44:55"},
+		"64-bit mac":        {Alias: "A", MAC: "11:22:33:44:55:66:77:88"},
+		"unknown interface": {Alias: "A", Interface: "ens9f0"},
+	}
+	for name, ident := range invalid {
+		cc := &ClockChain{CommonDefinitions: &CommonDefinitions{ClockIdentifiers: []ClockIdentifier{ident}}}
+		if _, err := cc.BuildClockAliasMap(); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		} else {
+			t.Logf("✅ %s rejected: %v", name, err)
+		}
+	}
+}
+
+// TestClockIDNormalization tests that decimal and hex clock IDs of the same value are equivalent
+func TestClockIDNormalization(t *testing.T) {
+	hex, err := ParseClockID("0x507c6fffff0ac7be")
+	if err != nil {
+		t.Fatalf("Failed to parse hex clock ID: %v", err)
+	}
+	decimal, err := ParseClockID("5799633565432596414")
+	if err != nil {
+		t.Fatalf("Failed to parse decimal clock ID: %v", err)
+	}
+	if hex != decimal {
+		t.Errorf("Expected equal clock IDs, got %s and %s", hex, decimal)
+	}
+	if leading, err := ParseClockID("0123"); err != nil || leading != 123 {
+		t.Errorf("Expected decimal 123 for 0123, got %d (%v)", leading, err)
+	}
+	for _, invalid := range []string{"0x1507c6fffff0ac7be", "18446744073709551616", "0x", "12ab", "-1", "0x_12", ""} {
+		if _, err := ParseClockID(invalid); err == nil {
+			t.Errorf("Expected clock ID %q to be rejected", invalid)
+		} else {
+			t.Logf("✅ %q rejected: %v", invalid, err)
+		}
+	}
+
+	if hex.Format(ClockIDFormatDecimal) != "5799633565432596414" || hex.Format(ClockIDFormatHex) != "0x507c6fffff0ac7be" {
+		t.Errorf("Unexpected formats: %s, %s", hex.Format(ClockIDFormatDecimal), hex.Format(ClockIDFormatHex))
+	}
+	canonical := CanonicalClockIDFormat
+	CanonicalClockIDFormat = ClockIDFormatDecimal
+	marshaled, err := yaml.Marshal(struct {
+		ClockID ClockID `yaml:"clockId"`
+	}{hex})
+	CanonicalClockIDFormat = canonical
+	if err != nil || string(marshaled) != "clockId: \"5799633565432596414\"\n" {
+		t.Errorf("Unexpected YAML in decimal canonical form: %q (%v)", marshaled, err)
+	}
+
+	// The canonical form is set by the --clock-id-format flag
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	addLoadFlags(flags, false)
+	if err := flags.Parse([]string{"--clock-id-format", "decimal"}); err != nil || CanonicalClockIDFormat != ClockIDFormatDecimal {
+		t.Errorf("Expected --clock-id-format to set the decimal canonical form, got %s (%v)", CanonicalClockIDFormat, err)
+	}
+	CanonicalClockIDFormat = canonical
+	if err := flags.Parse([]string{"--clock-id-format", "octal"}); err == nil {
+		t.Errorf("Expected --clock-id-format octal to be rejected")
+	}
+
+	// A plain yaml.Unmarshal resolves the aliases used as clock IDs
+	var aliased ClockChain
+	err = yaml.Unmarshal([]byte(`
+commonDefinitions:
+  clockIdentifiers:
+  - alias: Leader
+    clockId: "0x507c6fffff0ac7be"
+structure:
+- name: Leader
+  dpll:
+    clockId: Leader
+`), &aliased)
+	if err != nil || aliased.Structure[0].DPLL.ClockID != hex {
+		t.Errorf("Expected the Leader alias to resolve to %s, got %+v (%v)", hex, aliased.Structure, err)
+	}
+	if err := aliased.ResolveClockAliases(); err != nil {
+		t.Errorf("Expected the clock identifiers to resolve, got %v", err)
+	}
+
+	pm, err := NewPluginManager("plugins")
+	if err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+	testConfig := `
+structure:
+- name: Leader
+  hardwarePlugin: e810
+  dpll:
+    clockId: "5799633565432596414"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+- name: Duplicate
+  dpll:
+    clockId: "0x507C6FFFFF0AC7BE"
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x507c6fffff0ac7be"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x507c6fffff0ac7be"
+      boardLabel: GNSS_1PPS
+      eec:
+        priority: 3
+`
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	if err := pm.MergeUserConfigWithDefaults(config); err != nil {
+		t.Fatalf("Failed to merge plugin defaults: %v", err)
+	}
+
+	// The plugin default for GNSS_1PPS is merged into the user's desired state instead of duplicating it
+	count := 0
+	for _, ds := range config.Behavior.Conditions[0].DesiredStates {
+		if ds.BoardLabel == "GNSS_1PPS" {
+			count++
+			if ds.EEC == nil || *ds.EEC.Priority != 3 || ds.PPS == nil {
+				t.Errorf("Expected the user EEC priority and the plugin PPS default, got %+v", ds)
+			}
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected one desired state for GNSS_1PPS, got %d", count)
+	}
+
+	err = config.ValidateWithPlugins(pm)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+	for _, finding := range errs {
+		if finding.Severity != SeverityError {
+			continue
+		}
+		if finding.Rule != RuleClockIDDuplicate || finding.Path != "structure[1].dpll.clockId" {
+			t.Errorf("Unexpected finding: %v", finding)
+		}
+		t.Logf("✅ %v", finding)
+	}
+	if !errs.HasErrors() {
+		t.Error("Expected a duplicate clock ID error")
+	}
+}
+
+// TestEnumDecoding tests that unknown source types, condition types and pin states are rejected when
+// decoding, with the valid values and the closest one
+func TestEnumDecoding(t *testing.T) {
+	const template = `
+structure:
+- name: EnumTestSubsystem
+  dpll:
+    clockId: "0x123"
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x123"
+    sourceType: %s
+    boardLabel: REF0
+  conditions:
+  - name: "Locked"
+    sources:
+    - sourceName: GNSS
+      conditionType: %s
+    desiredStates:
+    - clockId: "0x123"
+      boardLabel: REF0
+      eec:
+        state: %s
+`
+	config, _, err := ParseClockChain([]byte(fmt.Sprintf(template, "gnss", "init", "selectable")))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	if config.Behavior.Sources[0].SourceType != SourceTypeGNSS ||
+		config.Behavior.Conditions[0].Sources[0].ConditionType != ConditionTypeInit ||
+		config.Behavior.Conditions[0].DesiredStates[0].EEC.State != PinStateSelectable {
+		t.Errorf("Unexpected decoded values: %+v", config.Behavior)
+	}
+	data, err := yaml.Marshal(config.Behavior.Conditions[0].DesiredStates[0].EEC)
+	if err != nil || !strings.Contains(string(data), "state: selectable") {
+		t.Errorf("Expected the pin state to marshal as a string, got %q (%v)", data, err)
+	}
+
+	tests := []struct {
+		sourceType, conditionType, state string
+		expected                         string
+	}{
+		{"gps", "locked", "connected", `line 10: invalid sourceType "gps" (did you mean "gnss"?), valid values: ptpTimeReceiver, gnss`},
+		{"gnss", "lockd", "connected", `invalid conditionType "lockd" (did you mean "locked"?)`},
+		{"gnss", "locked", "Connected", `invalid state "Connected" (did you mean "connected"?)`},
+		{"gnss", "locked", "enabled", `invalid state "enabled", valid values: connected, disconnected, selectable`},
+	}
+	for _, test := range tests {
+		_, _, err := ParseClockChain([]byte(fmt.Sprintf(template, test.sourceType, test.conditionType, test.state)))
+		if err == nil {
+			t.Errorf("Expected %q to be rejected", test.expected)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("Expected an error containing %q, got %v", test.expected, err)
+			continue
+		}
+		t.Logf("✅ %v", err)
+	}
+}
+
+// TestInitCondition tests that init conditions are applied after the default conditions and before any
+// source locks, receive plugin init defaults, and are unique and listed in that order
+func TestInitCondition(t *testing.T) {
+	pluginsDir := t.TempDir()
+	plugin := `
+pluginInfo:
+  name: bc-test
+specificDefaults:
+  GNSS_1PPS:
+    eec:
+      priority: 0
+initDefaults:
+  GNSS_1PPS:
+    eec:
+      priority: 255
+`
+	if err := os.WriteFile(filepath.Join(pluginsDir, "bc-test.yaml"), []byte(plugin), 0o644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	pm, err := NewPluginManager(pluginsDir)
+	if err != nil {
+		t.Fatalf("Failed to load plugins: %v", err)
+	}
+
+	testConfig := `
+structure:
+- name: BC
+  hardwarePlugin: bc-test
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+      CVL_SDP22:
+        frequency: 1
+behavior:
+  sources:
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0"]
+  conditions:
+  - name: "PTP Init"
+    sources:
+    - sourceName: PTP
+      conditionType: init
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 255
+  - name: "PTP Active"
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      eec:
+        priority: 0
+`
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	if err := pm.MergeUserConfigWithDefaults(config); err != nil {
+		t.Fatalf("Failed to merge plugin defaults: %v", err)
+	}
+	if err := config.ValidateWithPlugins(pm); err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+
+	// The user init condition receives the plugin init defaults, the auto-generated default condition the plugin defaults
+	expected := map[string]float64{
+		"PTP Init/GNSS_1PPS": 255,
+		"PTP Init/CVL_SDP22": 255,
+		"Default Configuration (Auto-generated)/GNSS_1PPS": 0,
+	}
+	for _, condition := range config.Behavior.Conditions {
+		for _, ds := range condition.DesiredStates {
+			key := condition.Name + "/" + ds.BoardLabel
+			if priority, ok := expected[key]; ok {
+				if ds.EEC == nil || ds.EEC.Priority == nil || *ds.EEC.Priority != priority {
+					t.Errorf("Expected %s EEC priority %g, got %+v", key, priority, ds.EEC)
+				}
+				delete(expected, key)
+			}
+		}
+	}
+	for key := range expected {
+		t.Errorf("Expected a desired state for %s", key)
+	}
+
+	gnss := NewPinKey(0x112233fffe445566, "GNSS_1PPS")
+	if config.DefaultPinTable()[gnss].EEC.Priority == nil || *config.DefaultPinTable()[gnss].EEC.Priority != 0 {
+		t.Errorf("Expected GNSS enabled by the default condition, got %v", config.DefaultPinTable()[gnss].EEC)
+	}
+	engine, err := NewEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	if state := engine.Table()[gnss].EEC; state.Priority == nil || *state.Priority != 255 {
+		t.Errorf("Expected the init condition to disable GNSS when the engine starts, got %v", state)
+	}
+	step, err := engine.Handle(SourceEvent{"PTP", SourceLocked})
+	if err != nil || step.Condition == nil || step.Condition.Name != "PTP Active" {
+		t.Fatalf("Expected PTP Active to fire, got %+v (%v)", step, err)
+	}
+	t.Logf("✅ Init condition applied between the default condition and PTP Active")
+
+	transition, err := BuildTransitionPlan(config, "", "PTP Init")
+	if err != nil {
+		t.Fatalf("Failed to build transition: %v", err)
+	}
+	if len(transition.Steps) != 2 {
+		t.Errorf("Expected 2 steps from the default condition to the init condition, got %d", len(transition.Steps))
+	}
+
+	// Without a user init condition, one is generated for the plugin init defaults after the default condition
+	generated, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	generated.Behavior.Conditions = generated.Behavior.Conditions[1:]
+	if err := pm.MergeUserConfigWithDefaults(generated); err != nil {
+		t.Fatalf("Failed to merge plugin defaults: %v", err)
+	}
+	initCondition := generated.Behavior.Conditions[1]
+	if !isInitCondition(&initCondition) || initCondition.Sources[0].SourceName != InitSourceName || len(initCondition.DesiredStates) != 1 {
+		t.Errorf("Expected a generated init condition with the plugin init defaults, got %+v", initCondition)
+	}
+	if err := generated.ValidateWithPlugins(pm); err != nil {
+		t.Errorf("Expected the generated init condition to validate, got %v", err)
+	}
+
+	// An init condition listed after a locked condition, or before a default condition, is rejected
+	conditions := config.Behavior.Conditions
+	for _, order := range [][]int{{0, 2, 1}, {1, 0, 2}} {
+		config.Behavior.Conditions = []Condition{conditions[order[0]], conditions[order[1]], conditions[order[2]]}
+		err = config.ValidateWithPlugins(pm)
+		var errs ValidationErrors
+		if !errors.As(err, &errs) || len(errs.Filter(SeverityError)) != 1 || errs.Filter(SeverityError)[0].Rule != RuleConditionOrder {
+			t.Errorf("Expected a condition order error for conditions %v, got %v", order, err)
+			continue
+		}
+		t.Logf("✅ %v", errs.Filter(SeverityError)[0])
+	}
+	config.Behavior.Conditions = conditions
+
+	// A second init condition, and init used as a supporting condition, are rejected
+	config.Behavior.Conditions = append(config.Behavior.Conditions,
+		Condition{Name: "Second Init", Sources: []SourceState{{SourceName: InitSourceName, ConditionType: ConditionTypeInit}}},
+		Condition{Name: "Mixed", Sources: []SourceState{{SourceName: "PTP", ConditionType: ConditionTypeLost}, {SourceName: "PTP", ConditionType: ConditionTypeInit}}})
+	err = config.ValidateWithPlugins(pm)
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected validation errors, got %v", err)
+	}
+	found := 0
+	for _, finding := range errs {
+		if finding.Rule == RuleConditionType {
+			found++
+			t.Logf("✅ %v", finding)
+		}
+	}
+	if found != 2 {
+		t.Errorf("Expected 2 condition type findings, got %d: %v", found, errs)
+	}
+}
+
+// TestStrictDecoding tests that unknown keys in configurations and plugins are rejected with their
+// position and the closest valid field, while extension keys are ignored
+func TestStrictDecoding(t *testing.T) {
+	testConfig := `
+x-generator: lab-inventory
+structure:
+- name: Strict
+  x-rack: A3
+  dpll:
+    clockId: "0x123"
+    phaseInput:
+      REF0:
+        frequency: 1
+    phaseOutputs:
+      SMA1:
+        frequency: 1
+        esyncConfig: esync1
+`
+	_, _, err := ParseClockChain([]byte(testConfig))
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Expected unknown field findings, got %v", err)
+	}
+	expected := []struct {
+		path       string
+		line       int
+		suggestion string
+	}{
+		{"structure[0].dpll.phaseInput", 8, `(did you mean "phaseInputs"?)`},
+		{"structure[0].dpll.phaseOutputs.SMA1.esyncConfig", 14, `(did you mean "esyncConfigName"?)`},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, exp := range expected {
+		finding := errs[i]
+		if finding.Rule != RuleUnknownField || finding.Path != exp.path || finding.Line != exp.line ||
+			!strings.Contains(finding.Message, exp.suggestion) {
+			t.Errorf("Expected an unknown field at %s (line %d) %s, got %v", exp.path, exp.line, exp.suggestion, finding)
+			continue
+		}
+		t.Logf("✅ %v", finding)
+	}
+
+	// Extension keys alone are accepted
+	valid := strings.Replace(strings.Replace(testConfig, "phaseInput:", "phaseInputs:", 1), "esyncConfig:", "x-esyncConfig:", 1)
+	if _, _, err := ParseClockChain([]byte(valid)); err != nil {
+		t.Errorf("Expected extension keys to be ignored, got %v", err)
+	}
+
+	pluginsDir := t.TempDir()
+	plugin := `
+pluginInfo:
+  name: strict-test
+specificDefault:
+  GNSS_1PPS:
+    eec:
+      priority: 0
+`
+	if err := os.WriteFile(filepath.Join(pluginsDir, "strict-test.yaml"), []byte(plugin), 0o644); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	if _, err := NewPluginManager(pluginsDir); err == nil || !strings.Contains(err.Error(), `unknown field "specificDefault" (did you mean "specificDefaults"?)`) {
+		t.Errorf("Expected the misspelled plugin key to be rejected, got %v", err)
+	} else {
+		t.Logf("✅ %v", err)
+	}
+}
+
+// TestFindingSuppression tests that findings are suppressed by the lint section and lint:ignore
+// comments, and that the fail-on severity decides whether the remaining findings fail the run
+func TestFindingSuppression(t *testing.T) {
+	testConfig := `
+lint:
+  suppress:
+  - rule: pin-description
+    path: structure[0]
+    reason: documented in the lab inventory
+structure:
+- name: A
+  dpll:
+    clockId: "0x1"
+    phaseOutputs:
+      OUT3: # lint:ignore dangling-output -- spare output for measurements
+        connector: C9
+        frequency: 1
+- name: B
+  dpll:
+    clockId: "0x2"
+    phaseInputs:
+      REF1:
+        frequency: 1
+`
+	path := filepath.Join(t.TempDir(), "suppress.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	loaded, err := LoadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if err := loaded.Config.ValidateWithPlugins(loaded.Plugins); err != nil {
+		t.Errorf("Expected info findings not to fail validation, got %v", err)
+	}
+	if findings := loaded.Config.Findings(loaded.Plugins); findings.HasErrors() || len(findings) == 0 {
+		t.Errorf("Expected advisory findings only, got:\n%v", findings)
+	}
+
+	if len(loaded.Findings) != 1 || loaded.Findings[0].Rule != RulePinDescription ||
+		loaded.Findings[0].Path != "structure[1].dpll.phaseInputs.REF1" {
+		t.Errorf("Expected only the pin description finding of subsystem B, got:\n%v", loaded.Findings)
+	}
+	suppressed := map[string]bool{
+		RulePinDescription + " structure[0].dpll.phaseOutputs.OUT3": false,
+		RuleDanglingOutput + " structure[0].dpll.phaseOutputs.OUT3": false,
+	}
+	for _, finding := range loaded.Suppressed {
+		key := finding.Rule + " " + finding.Path
+		if _, ok := suppressed[key]; !ok {
+			t.Errorf("Unexpected suppressed finding: %v", finding)
+		}
+		suppressed[key] = true
+		t.Logf("🔇 %v", finding)
+	}
+	for key, found := range suppressed {
+		if !found {
+			t.Errorf("Expected %s to be suppressed", key)
+		}
+	}
+
+	tests := []struct {
+		failOn Severity
+		fails  bool
+	}{
+		{SeverityError, false},
+		{SeverityWarning, false},
+		{SeverityInfo, true},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		if fails := loaded.reportFindings(&out, &ReportOptions{FailOn: test.failOn, MinSeverity: SeverityWarning}); fails != test.fails {
+			t.Errorf("fail-on %s: expected failure %v, got %v:\n%s", test.failOn, test.fails, fails, out.String())
+		}
+	}
+	if _, err := ParseSeverity("warn"); err == nil || !strings.Contains(err.Error(), `did you mean "warning"?`) {
+		t.Errorf("Expected a suggestion for an invalid severity, got %v", err)
+	}
+}
+
+// TestLint tests that the lint rules report unused definitions, unused sources and dead conditions,
+// and that rules can be registered
+func TestLint(t *testing.T) {
+	testConfig := `
+commonDefinitions:
+  clockIdentifiers:
+  - alias: Leader
+    clockId: "0x1"
+  - alias: Spare
+    clockId: "0x2"
+  eSyncDefinitions:
+  - name: used
+    esyncConfig:
+      transferFrequency: 10000000
+  - name: unused
+    esyncConfig:
+      transferFrequency: 10000000
+  refSyncDefinitions:
+  - name: pair
+    relatedPinBoardLabel: REF0
+structure:
+- name: Leader
+  dpll:
+    clockId: Leader
+    phaseInputs:
+      REF0:
+        esyncConfigName: used
+        description: eSync input
+behavior:
+  sources:
+  - name: GNSS
+    clockId: Leader
+    sourceType: gnss
+    boardLabel: REF0
+  - name: Backup
+    clockId: Leader
+    sourceType: gnss
+    boardLabel: REF0
+  conditions:
+  - name: GNSS Locked
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: Leader
+      boardLabel: REF9
+      eec:
+        priority: 0
+`
+	path := filepath.Join(t.TempDir(), "lint.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	RegisterLintRule(LintRule{ID: "test-subsystem-count", Severity: SeverityInfo, Description: "number of subsystems",
+		Check: func(ctx *LintContext, report LintReporter) {
+			report("structure", "%d subsystem(s)", len(ctx.Config.Structure))
+		}})
+	defer delete(lintRules, "test-subsystem-count")
+
+	loaded, err := LoadConfig(path, io.Discard)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	loaded.Lint()
+
+	expected := map[string]string{
+		RuleUnusedAlias:        "commonDefinitions.clockIdentifiers[1]",
+		RuleUnusedESync:        "commonDefinitions.eSyncDefinitions[1]",
+		RuleUnusedRefSync:      "commonDefinitions.refSyncDefinitions[0]",
+		RuleUnusedSource:       "behavior.sources[1]",
+		RuleDeadCondition:      "behavior.conditions[0]",
+		"test-subsystem-count": "structure",
+	}
+	for _, finding := range loaded.Findings {
+		path, ok := expected[finding.Rule]
+		if !ok {
+			continue
+		}
+		if finding.Path != path || finding.Line == 0 {
+			t.Errorf("Expected %s at %s with a position, got %v", finding.Rule, path, finding)
+			continue
+		}
+		delete(expected, finding.Rule)
+		t.Logf("✅ %v", finding)
+	}
+	for rule, path := range expected {
+		t.Errorf("Expected a %s finding at %s", rule, path)
+	}
+
+	if !loaded.reportFindings(io.Discard, &ReportOptions{FailOn: SeverityWarning, MinSeverity: SeverityInfo}) {
+		t.Error("Expected lint warnings to fail the run")
+	}
+}
+
+// TestRefSyncDefinitions tests that pins reference refSync definitions by name, resolved within their subsystem
+func TestRefSyncDefinitions(t *testing.T) {
+	testConfig := `
+commonDefinitions:
+  refSyncDefinitions:
+  - name: pair
+    relatedPinBoardLabel: REF0
+  - name: unrelated
+structure:
+- name: A
+  dpll:
+    clockId: "0x1"
+    phaseInputs:
+      REF0:
+        frequency: 1
+    frequencyInputs:
+      CLK0:
+        frequency: 10000000
+        refSyncConfigName: pair
+      CLK1:
+        frequency: 10000000
+        refSyncConfigName: missing
+      CLK2:
+        frequency: 10000000
+        refSyncConfigName: unrelated
+    frequencyOutputs:
+      OUT0:
+        frequency: 10000000
+        refSyncConfigName: pair
+- name: B
+  dpll:
+    clockId: "0x2"
+    phaseInputs:
+      REF1:
+        frequency: 1
+    frequencyInputs:
+      CLK0:
+        frequency: 10000000
+        refSyncConfigName: pair
+`
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	config.ExpandRefSyncDefinitions()
+	if got := config.Structure[0].DPLL.FrequencyInputs["CLK0"].ReferenceSync; got != "REF0" {
+		t.Errorf("Expected CLK0 of A to be paired with REF0, got %q", got)
+	}
+
+	var errs ValidationErrors
+	if !errors.As(config.Validate(), &errs) {
+		t.Fatalf("Expected validation errors")
+	}
+	expected := map[string]bool{
+		"structure[0].dpll.frequencyInputs.CLK1.refSyncConfigName":  false, // unknown definition
+		"structure[0].dpll.frequencyInputs.CLK2.refSyncConfigName":  false, // no related pin
+		"structure[0].dpll.frequencyOutputs.OUT0.refSyncConfigName": false, // frequency output
+		"structure[1].dpll.frequencyInputs.CLK0.refSyncConfigName":  false, // REF0 is not a phase pin of B
+	}
+	for _, finding := range errs {
+		if _, ok := expected[finding.Path]; !ok || finding.Rule != RuleReferenceSync {
+			t.Errorf("Unexpected finding: %v", finding)
+			continue
+		}
+		expected[finding.Path] = true
+		t.Logf("✅ %v", finding)
+	}
+	for path, found := range expected {
+		if !found {
+			t.Errorf("Expected a reference-sync finding at %s", path)
+		}
+	}
+}
+
+// TestConditionAnalysis tests the detection of ambiguous winners, unreachable conditions and conflicting pin states
+func TestConditionAnalysis(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+      CVL_SDP22:
+        frequency: 1
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0"]
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+  - name: GNSS Active
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 0
+  - name: PTP Active
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 1
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 3
+  - name: GNSS Lost
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+  - name: PTP Lost
+    sources:
+    - sourceName: PTP
+      conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 2
+  - name: GNSS Lost Again
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    desiredStates: []
+  - name: Flapping
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    - sourceName: GNSS
+      conditionType: lost
+    desiredStates: []
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	analysis, err := AnalyzeConditions(config)
+	if err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+
+	if len(analysis.Combinations) != 4 || analysis.Ambiguous() != 2 {
+		t.Fatalf("Expected 4 combinations with 2 ambiguous, got %d with %d", len(analysis.Combinations), analysis.Ambiguous())
+	}
+	allLost := analysis.Combinations[0]
+	if allLost.States.String() != "GNSS lost, PTP lost" || strings.Join(allLost.Conditions, "|") != "GNSS Lost|PTP Lost" {
+		t.Errorf("Unexpected analysis of %v: %v", allLost.States, allLost.Conditions)
+	}
+	// GNSS has priority, so PTP lost fails over to the GNSS locked conditions
+	gnssLocked := analysis.Combinations[2]
+	if outcome := gnssLocked.Outcomes[1]; outcome.Condition != "GNSS Active" || !outcome.Failover {
+		t.Errorf("Expected %v to fail over to GNSS Active, got %+v", gnssLocked.States, outcome)
+	}
+	if strings.Join(analysis.Unreachable, "|") != "GNSS Lost Again|Flapping" {
+		t.Errorf("Unexpected unreachable conditions: %v", analysis.Unreachable)
+	}
+
+	expected := map[string]string{
+		"behavior.conditions[3]":                  RuleAmbiguousCondition,
+		"behavior.conditions[4]":                  RuleAmbiguousCondition,
+		"behavior.conditions[4].desiredStates[1]": RuleConflictingPinState,
+		"behavior.conditions[2].desiredStates[1]": RuleConflictingPinState,
+		"behavior.conditions[5]":                  RuleUnreachableCondition,
+		"behavior.conditions[6]":                  RuleUnreachableCondition,
+	}
+	for _, finding := range analysis.Findings {
+		if expected[finding.Path] != finding.Rule {
+			t.Errorf("Unexpected finding %v", finding)
+			continue
+		}
+		delete(expected, finding.Path)
+		t.Logf("✅ %v", finding)
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected a %s finding at %s", rule, path)
+	}
+}
+
+// TestCoverage tests the truth table of source combinations and its formats
+func TestCoverage(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  ethernet:
+  - ports: ["ens4f0"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+      CVL_SDP22:
+        frequency: 1
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  - name: PTP
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0"]
+  conditions:
+  - name: Defaults
+    sources:
+    - sourceName: "Default on profile (re)load"
+      conditionType: default
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 255
+  - name: GNSS Active
+    sources:
+    - sourceName: GNSS
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 0
+  - name: PTP Active
+    sources:
+    - sourceName: PTP
+      conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 1
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	coverage, err := BuildCoverage(config)
+	if err != nil {
+		t.Fatalf("Coverage failed: %v", err)
+	}
+
+	// No condition handles both sources lost, the default condition only runs on profile (re)load;
+	// GNSS lost with PTP locked fails over to PTP Active
+	expected := []string{"", "PTP Active", "GNSS Active", "GNSS Active"}
+	if len(coverage.Rows) != len(expected) || coverage.Uncovered != 1 || coverage.Combinations != 4 {
+		t.Fatalf("Expected 4 rows with 1 uncovered combination, got %d rows with %d uncovered", len(coverage.Rows), coverage.Uncovered)
+	}
+	for i, row := range coverage.Rows {
+		if row.Condition != expected[i] {
+			t.Errorf("Row %d (%v): expected condition %q, got %q", i, row.States, expected[i], row.Condition)
+			continue
+		}
+		t.Logf("📋 %v -> %q (%s)", row.States, row.Condition, row.events())
+	}
+	if len(coverage.Pins) != 2 {
+		t.Errorf("Expected both pins to change in some row, got %v", coverage.Pins)
+	}
+
+	markdown, err := coverage.Format(CoverageFormatMarkdown)
+	if err != nil || !strings.Contains(markdown, "| lost | lost | **uncovered** |") ||
+		!strings.Contains(markdown, "| lost | locked | PTP Active | GNSS lost (failover) or PTP locked | - / prio 1 | - / prio 255 |") {
+		t.Errorf("Unexpected Markdown table (%v):\n%s", err, markdown)
+	}
+
+	records, err := coverage.Format(CoverageFormatCSV)
+	if err != nil {
+		t.Fatalf("CSV rendering failed: %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(records)).ReadAll()
+	if err != nil || len(rows) != 5 || rows[1][3] != "false" || rows[4][2] != "GNSS Active" {
+		t.Errorf("Unexpected CSV (%v):\n%s", err, records)
+	}
+
+	data, err := coverage.Format(CoverageFormatJSON)
+	if err != nil {
+		t.Fatalf("JSON rendering failed: %v", err)
+	}
+	var decoded Coverage
+	if err := json.Unmarshal([]byte(data), &decoded); err != nil || decoded.Uncovered != 1 || len(decoded.Rows[1].Pins) != 2 {
+		t.Errorf("Unexpected JSON (%v):\n%s", err, data)
+	}
+
+	if _, err := coverage.Format("html"); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+
+	// A lost condition matching both sources lost covers the combination
+	config.Behavior.Conditions = append(config.Behavior.Conditions, Condition{
+		Name: "All Lost",
+		Sources: []SourceState{
+			{SourceName: "PTP", ConditionType: ConditionTypeLost},
+			{SourceName: "GNSS", ConditionType: ConditionTypeLost},
+		},
+	})
+	coverage, err = BuildCoverage(config)
+	if err != nil {
+		t.Fatalf("Coverage failed: %v", err)
+	}
+	if coverage.Uncovered != 0 || coverage.Rows[0].Condition != "All Lost" {
+		t.Errorf("Expected All Lost to cover both sources lost, got %+v", coverage.Rows[0])
+	}
+}
+
+// TestConditionExpressions tests any/all/not expressions in condition sources, in the engine, in
+// validation and in the condition analysis
+func TestConditionExpressions(t *testing.T) {
+	testConfig := `
+structure:
+- name: Leader
+  ethernet:
+  - ports: ["ens4f0", "ens4f1"]
+  dpll:
+    clockId: "0x112233fffe445566"
+    phaseInputs:
+      GNSS_1PPS:
+        frequency: 1
+      CVL_SDP22:
+        frequency: 1
+      CVL_SDP20:
+        frequency: 1
+      REF0:
+        frequency: 10000000
+behavior:
+  sources:
+  - name: GNSS
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: GNSS_1PPS
+  - name: PTP1
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP22
+    ptpTimeReceivers: ["ens4f0"]
+  - name: PTP2
+    clockId: "0x112233fffe445566"
+    sourceType: ptpTimeReceiver
+    boardLabel: CVL_SDP20
+    ptpTimeReceivers: ["ens4f1"]
+  - name: Ethernet
+    clockId: "0x112233fffe445566"
+    sourceType: gnss
+    boardLabel: REF0
+  conditions:
+  - name: GNSS Lost, a PTP Lost
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    - any:
+      - sourceName: PTP1
+        conditionType: lost
+      - sourceName: PTP2
+        conditionType: lost
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: GNSS_1PPS
+      pps:
+        priority: 255
+  - name: PTP1 Locked, Ethernet Not Locked
+    sources:
+    - sourceName: PTP1
+      conditionType: locked
+    - not:
+        sourceName: Ethernet
+        conditionType: locked
+    desiredStates:
+    - clockId: "0x112233fffe445566"
+      boardLabel: CVL_SDP22
+      pps:
+        priority: 0
+`
+
+	config, _, err := ParseClockChain([]byte(testConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if got := config.Behavior.Conditions[0].Sources[1].String(); got != "PTP1 lost OR PTP2 lost" {
+		t.Errorf("Unexpected expression string %q", got)
+	}
+	nested := SourceState{All: []SourceState{{SourceName: "GNSS", ConditionType: ConditionTypeLost}, config.Behavior.Conditions[0].Sources[1]}}
+	if got := nested.String(); got != "GNSS lost AND (PTP1 lost OR PTP2 lost)" {
+		t.Errorf("Unexpected nested expression string %q", got)
+	}
+
+	engine, err := NewEngine(config)
+	if err != nil {
+		t.Fatalf("Failed to create engine: %v", err)
+	}
+	steps := []struct {
+		event     SourceEvent
+		condition string
+	}{
+		{SourceEvent{"GNSS", SourceLocked}, ""},
+		{SourceEvent{"GNSS", SourceLost}, "GNSS Lost, a PTP Lost"},
+		{SourceEvent{"PTP1", SourceLocked}, "PTP1 Locked, Ethernet Not Locked"},
+		{SourceEvent{"Ethernet", SourceLocked}, ""},
+		{SourceEvent{"PTP1", SourceLost}, ""},
+		// Ethernet is locked now, so the not expression does not hold
+		{SourceEvent{"PTP1", SourceLocked}, ""},
+	}
+	for i, expected := range steps {
+		step, err := engine.Handle(expected.event)
+		if err != nil {
+			t.Fatalf("Step %d: %v", i, err)
+		}
+		name := ""
+		if step.Condition != nil {
+			name = step.Condition.Name
+		}
+		if name != expected.condition {
+			t.Errorf("Step %d (%v): expected condition %q, got %q", i, expected.event, expected.condition, name)
+			continue
+		}
+		t.Logf("⚡ %v -> %q", expected.event, name)
+	}
+
+	analysis, err := AnalyzeConditions(config)
+	if err != nil {
+		t.Fatalf("Analysis failed: %v", err)
+	}
+	// Combinations are ordered like a truth table of GNSS, PTP1, PTP2 and Ethernet
+	for combination, expected := range map[int]string{
+		0b0010: "GNSS Lost, a PTP Lost",
+		0b0110: "PTP1 Locked, Ethernet Not Locked",
+		0b0111: "",
+	} {
+		result := analysis.Combinations[combination]
+		if strings.Join(result.Conditions, "|") != expected {
+			t.Errorf("%v: expected %q, got %v", result.States, expected, result.Conditions)
+			continue
+		}
+		t.Logf("✅ %v -> %q", result.States, expected)
+	}
+
+	invalidConfig := strings.Replace(testConfig, `
+  - name: PTP1 Locked, Ethernet Not Locked`, `
+  - name: Expression Trigger
+    sources:
+    - any:
+      - sourceName: GNSS
+        conditionType: lost
+    desiredStates: []
+  - name: Mixed
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    - sourceName: PTP1
+      conditionType: lost
+      not:
+        sourceName: PTP2
+        conditionType: lost
+    desiredStates: []
+  - name: Nested
+    sources:
+    - sourceName: GNSS
+      conditionType: lost
+    - all:
+      - sourceName: PTP3
+        conditionType: lost
+      - not:
+          sourceName: PTP2
+          conditionType: init
+    - any: []
+    desiredStates: []
+  - name: PTP1 Locked, Ethernet Not Locked`, 1)
+	config, _, err = ParseClockChain([]byte(invalidConfig))
+	if err != nil {
+		t.Fatalf("YAML parsing failed: %v", err)
+	}
+	var errs ValidationErrors
+	if !errors.As(config.Validate(), &errs) {
+		t.Fatal("Expected validation errors for invalid expressions")
+	}
+	expected := map[string]string{
+		"behavior.conditions[1].sources[0]":                          RuleSourceExpression,
+		"behavior.conditions[2].sources[1]":                          RuleSourceExpression,
+		"behavior.conditions[3].sources[1].all[0].sourceName":        RuleConditionSource,
+		"behavior.conditions[3].sources[1].all[1].not.conditionType": RuleConditionType,
+		"behavior.conditions[3].sources[2].any":                      RuleSourceExpression,
+	}
+	for _, e := range errs {
+		if rule, ok := expected[e.Path]; ok && rule == e.Rule {
+			delete(expected, e.Path)
+			t.Logf("✅ %v", e)
+		}
+	}
+	for path, rule := range expected {
+		t.Errorf("Expected a %s finding at %s, got:\n%v", rule, path, errs)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// validate checks that the capabilities declared for a pin in a plugin file are well-formed
+func (c *PluginPinCapabilities) validate(label string) error {
+	if c.Direction != PinDirectionInput && c.Direction != PinDirectionOutput {
+		return fmt.Errorf("pin %s: direction must be %q or %q, got %q", label, PinDirectionInput, PinDirectionOutput, c.Direction)
+	}
+	if c.Kind != PinKindPhase && c.Kind != PinKindFrequency {
+		return fmt.Errorf("pin %s: kind must be %q or %q, got %q", label, PinKindPhase, PinKindFrequency, c.Kind)
+	}
+	if c.RefSyncPair != "" && (c.Direction != PinDirectionInput || c.Kind != PinKindFrequency) {
+		return fmt.Errorf("pin %s: refSyncPair is only supported on frequency inputs", label)
+	}
+	if c.PhaseAdjust != nil {
+		if c.PhaseAdjust.Min > c.PhaseAdjust.Max {
+			return fmt.Errorf("pin %s: phaseAdjust min %d is greater than max %d", label, c.PhaseAdjust.Min, c.PhaseAdjust.Max)
+		}
+		if c.PhaseAdjust.Granularity < 0 {
+			return fmt.Errorf("pin %s: phaseAdjust granularity must not be negative", label)
+		}
+	}
+	return nil
+}
+
+// validateDefaults checks the specificDefaults and initDefaults of a plugin against its pin capabilities:
+// priorities can only be set on input pins and states only on output pins. Defaults for pins without
+// capabilities are not checked.
+func (p *HardwarePluginConfig) validateDefaults() error {
+	for _, set := range []struct {
+		name     string
+		defaults PluginSpecificDefaults
+	}{{"specificDefaults", p.SpecificDefaults}, {"initDefaults", p.InitDefaults}} {
+		for _, label := range set.defaults.Labels() {
+			caps, ok := p.Pins[label]
+			if !ok {
+				continue
+			}
+			pinDefaults := set.defaults[label]
+			for _, dpll := range []struct {
+				name     string
+				defaults *PluginPinDefaults
+			}{{"eec", pinDefaults.EEC}, {"pps", pinDefaults.PPS}} {
+				if dpll.defaults == nil {
+					continue
+				}
+				if dpll.defaults.Priority != nil && caps.Direction == PinDirectionOutput {
+					return fmt.Errorf("%s: pin %s %s: priority can only be set on input pins, %s is an output", set.name, label, dpll.name, label)
+				}
+				if dpll.defaults.State != "" && caps.Direction == PinDirectionInput {
+					return fmt.Errorf("%s: pin %s %s: state can only be set on output pins, %s is an input", set.name, label, dpll.name, label)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validatePinConfig checks a user pin configuration against the capabilities of the hardware pin.
+// The direction and kind are those of the DPLL pin map the user declared the pin in.
+func (c *PluginPinCapabilities) validatePinConfig(
+	vc *validationCollector,
+	path string,
+	label string,
+	direction PinDirection,
+	kind PinKind,
+	config PinConfig,
+	esyncConfigs map[string]ESyncConfig,
+	pluginName string,
+) {
+	if direction != c.Direction || kind != c.Kind {
+		vc.add(RuleHWDirection, path, "pin %s is a %s %s on %s hardware, but is declared as a %s %s",
+			label, c.Kind, c.Direction, pluginName, kind, direction)
+	}
+
+	if config.Frequency != nil && !c.supportsFrequency(*config.Frequency) {
+		vc.add(RuleHWFrequency, pathKey(path, "frequency"), "pin %s does not support %s on %s hardware (supported: %s)",
+			label, formatFrequency(*config.Frequency), pluginName, c.supportedFrequencies())
+	}
+
+	if config.ESyncConfigName != "" {
+		if !c.ESync {
+			vc.add(RuleHWESync, pathKey(path, "esyncConfigName"), "pin %s does not support eSync on %s hardware", label, pluginName)
+		} else if esync, ok := esyncConfigs[config.ESyncConfigName]; ok && !c.supportsFrequency(esync.TransferFrequency) {
+			vc.add(RuleHWFrequency, pathKey(path, "esyncConfigName"),
+				"pin %s does not support the eSync transfer frequency %s of %s on %s hardware (supported: %s)",
+				label, formatFrequency(esync.TransferFrequency), config.ESyncConfigName, pluginName, c.supportedFrequencies())
+		}
+	}
+
+	if config.ReferenceSync != "" && config.ReferenceSync != c.RefSyncPair {
+		if c.RefSyncPair == "" {
+			vc.add(RuleHWReferenceSync, pathKey(path, config.referenceSyncKey()), "pin %s does not support ref-sync pairing on %s hardware",
+				label, pluginName)
+		} else {
+			vc.add(RuleHWReferenceSync, pathKey(path, config.referenceSyncKey()), "pin %s can only be ref-sync paired with %s on %s hardware",
+				label, c.RefSyncPair, pluginName)
+		}
+	}
+
+	if config.PhaseAdjustment != nil {
+		// The hardware applies the sum of the internal and external delays on the programmable side
+		adjustment := config.PhaseAdjustment.Internal
+		if config.PhaseAdjustment.External != nil {
+			adjustment += *config.PhaseAdjustment.External
+		}
+		adjustPath := pathKey(path, "phaseAdjustment")
+		switch {
+		case c.PhaseAdjust == nil:
+			vc.add(RuleHWPhaseAdjust, adjustPath, "pin %s does not support phase adjustment on %s hardware", label, pluginName)
+		case adjustment < c.PhaseAdjust.Min || adjustment > c.PhaseAdjust.Max:
+			vc.add(RuleHWPhaseAdjust, adjustPath, "phase adjustment %d ps of pin %s is outside the %s hardware range [%d, %d] ps",
+				adjustment, label, pluginName, c.PhaseAdjust.Min, c.PhaseAdjust.Max)
+		case c.PhaseAdjust.Granularity > 1 && adjustment%c.PhaseAdjust.Granularity != 0:
+			vc.add(RuleHWPhaseAdjust, adjustPath, "phase adjustment %d ps of pin %s is not a multiple of the %s hardware granularity %d ps",
+				adjustment, label, pluginName, c.PhaseAdjust.Granularity)
+		}
+	}
+
+	if config.Connector != "" && !containsString(c.Connectors, config.Connector) {
+		if len(c.Connectors) == 0 {
+			vc.add(RuleHWConnector, pathKey(path, "connector"), "pin %s cannot be routed to a connector on %s hardware",
+				label, pluginName)
+		} else {
+			vc.add(RuleHWConnector, pathKey(path, "connector"), "pin %s cannot be routed to connector %s on %s hardware (supported: %s)",
+				label, config.Connector, pluginName, strings.Join(c.Connectors, ", "))
+		}
+	}
+}
+
+// supportsFrequency reports whether the pin supports a frequency in Hz
+func (c *PluginPinCapabilities) supportsFrequency(frequency float64) bool {
+	if len(c.Frequencies) == 0 {
+		return true
+	}
+	for _, f := range c.Frequencies {
+		if f == frequency {
+			return true
+		}
+	}
+	return false
+}
+
+// supportedFrequencies formats the supported frequencies for error messages
+func (c *PluginPinCapabilities) supportedFrequencies() string {
+	formatted := make([]string, len(c.Frequencies))
+	for i, f := range c.Frequencies {
+		formatted[i] = formatFrequency(f)
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// formatFrequency formats a frequency in Hz with a readable unit, e.g. 1PPS, 10MHz
+func formatFrequency(frequency float64) string {
+	switch {
+	case frequency == 1:
+		return "1PPS"
+	case frequency >= 1e6:
+		return strconv.FormatFloat(frequency/1e6, 'f', -1, 64) + "MHz"
+	case frequency >= 1e3:
+		return strconv.FormatFloat(frequency/1e3, 'f', -1, 64) + "kHz"
+	}
+	return strconv.FormatFloat(frequency, 'f', -1, 64) + "Hz"
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ClockID is a 64-bit DPLL clock ID. Configurations write it in decimal or in hex with a 0x prefix;
+// both forms of the same value compare equal once parsed.
+type ClockID uint64
+
+// ClockIDFormat is a textual form of clock IDs
+type ClockIDFormat string
+
+const (
+	// ClockIDFormatHex writes clock IDs as 0x followed by 16 lowercase hex digits, e.g. 0x112233fffe445566
+	ClockIDFormatHex ClockIDFormat = "hex"
+
+	// ClockIDFormatDecimal writes clock IDs in decimal, e.g. 1234606422428505446
+	ClockIDFormatDecimal ClockIDFormat = "decimal"
+)
+
+// ClockIDFormats lists the clock ID forms
+var ClockIDFormats = []ClockIDFormat{ClockIDFormatHex, ClockIDFormatDecimal}
+
+// CanonicalClockIDFormat is the form clock IDs are normalized to by alias resolution and written in.
+// It is set by the --clock-id-format flag.
+var CanonicalClockIDFormat = ClockIDFormatHex
+
+// ParseClockIDFormat parses a clock ID form name
+func ParseClockIDFormat(name string) (ClockIDFormat, error) {
+	names := make([]string, len(ClockIDFormats))
+	for i, format := range ClockIDFormats {
+		if string(format) == name {
+			return format, nil
+		}
+		names[i] = string(format)
+	}
+	return "", fmt.Errorf("invalid clock ID format %q%s, valid values: %s", name, didYouMean(name, names), strings.Join(names, ", "))
+}
+
+// String implements flag.Value
+func (f *ClockIDFormat) String() string {
+	if f == nil {
+		return ""
+	}
+	return string(*f)
+}
+
+// Set implements flag.Value
+func (f *ClockIDFormat) Set(value string) error {
+	format, err := ParseClockIDFormat(value)
+	if err != nil {
+		return err
+	}
+	*f = format
+	return nil
+}
+
+// ParseClockID parses a clock ID in decimal or in hex with a 0x prefix. Values that do not fit in
+// 64 bits are rejected.
+func ParseClockID(value string) (ClockID, error) {
+	digits, base := value, 10
+	if strings.HasPrefix(value, "0x") || strings.HasPrefix(value, "0X") {
+		digits, base = value[2:], 16
+	}
+	parsed, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("clock ID %s exceeds 64 bits", value)
+		}
+		return 0, fmt.Errorf("invalid clock ID format: %s (must be decimal or hex)", value)
+	}
+	return ClockID(parsed), nil
+}
+
+// Format writes the clock ID in the given form
+func (c ClockID) Format(format ClockIDFormat) string {
+	if format == ClockIDFormatDecimal {
+		return strconv.FormatUint(uint64(c), 10)
+	}
+	return fmt.Sprintf("0x%016x", uint64(c))
+}
+
+// String writes the clock ID in the canonical form
+func (c ClockID) String() string {
+	return c.Format(CanonicalClockIDFormat)
+}
+
+// MarshalYAML writes the clock ID as a string in the canonical form
+func (c ClockID) MarshalYAML() (interface{}, error) {
+	return c.String(), nil
+}
+
+// UnmarshalYAML parses a decimal or hex clock ID
+func (c *ClockID) UnmarshalYAML(node *yaml.Node) error {
+	parsed, err := ParseClockID(node.Value)
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// MarshalText writes the clock ID in the canonical form
+func (c ClockID) MarshalText() ([]byte, error) {
+	return []byte(c.String()), nil
+}
+
+// UnmarshalText parses a decimal or hex clock ID
+func (c *ClockID) UnmarshalText(text []byte) error {
+	parsed, err := ParseClockID(string(text))
+	if err != nil {
+		return err
+	}
+	*c = parsed
+	return nil
+}
+
+// UnmarshalJSON parses a clock ID from a JSON string, or from a JSON number as the netlink tools print it
+func (c *ClockID) UnmarshalJSON(data []byte) error {
+	return c.UnmarshalText(bytes.Trim(data, `"`))
+}
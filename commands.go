@@ -0,0 +1,220 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PluginDir is the directory hardware plugin files are loaded from
+var PluginDir = "plugins"
+
+// DiscoverClockIDs enables the discovery of omitted clock IDs from the DPLL netlink family and sysfs
+// when loading a configuration. It is off by default, so that loading does not depend on the host.
+var DiscoverClockIDs = false
+
+// LoadedConfig is a configuration file after parsing, alias resolution, merging with the hardware
+// plugin defaults and validation
+type LoadedConfig struct {
+	// Path is the configuration file
+	Path string
+
+	// Config is the merged configuration
+	Config *ClockChain
+
+	// Root is the YAML node tree of the source document as written, before alias resolution. It is
+	// used to locate findings.
+	Root *yaml.Node
+
+	// Plugins is the plugin manager, or nil if the plugins could not be loaded
+	Plugins *PluginManager
+
+	// Findings holds the schema and validation findings, located and sorted
+	Findings ValidationErrors
+
+	// Suppressed holds the findings suppressed by the lint section or by lint:ignore comments
+	Suppressed ValidationErrors
+}
+
+// ReportOptions control which findings a command reports and which ones make it fail
+type ReportOptions struct {
+	// FailOn is the least serious severity that fails the command
+	FailOn Severity
+
+	// MinSeverity is the least serious severity that is reported. Findings that fail the command
+	// are reported regardless.
+	MinSeverity Severity
+}
+
+// subcommands maps subcommand names to their implementations. Each one receives the arguments
+// following its name and returns the process exit code.
+var subcommands = map[string]func(args []string) int{
+	"analyze":  runAnalyze,
+	"coverage": runCoverage,
+	"graph":    runGraph,
+	"lint":     runLint,
+	"plan":     runPlan,
+	"simulate": runSimulate,
+	"status":   runStatus,
+}
+
+// LoadConfig reads, merges and validates a configuration file. Progress messages are written to log.
+// An error is returned if the file cannot be read, parsed or merged; validation problems are
+// reported as Findings instead.
+func LoadConfig(path string, log io.Writer) (*LoadedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file: %w", err)
+	}
+
+	config, root, resolved, err := parseClockChain(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing YAML: %w", err)
+	}
+	loaded := &LoadedConfig{Path: path, Config: config, Root: root}
+
+	// Check the document against the published OpenAPI schema. Clock aliases are resolved first, as
+	// the schema only accepts clock IDs.
+	schema, err := loadConfigSchema()
+	if err != nil {
+		return nil, fmt.Errorf("loading schema: %w", err)
+	}
+	loaded.Findings = append(loaded.Findings, schema.ValidateDocument(resolved)...)
+
+	// Expand refSync definition names into the pins referencing them
+	config.ExpandRefSyncDefinitions()
+
+	// Discover omitted clock IDs before plugin defaults are merged by clock ID
+	loaded.Findings = append(loaded.Findings, discoverClockIDs(config, DiscoverClockIDs)...)
+
+	// Load hardware plugins and apply defaults
+	pluginManager, err := NewPluginManager(PluginDir)
+	if err != nil {
+		fmt.Fprintf(log, "Warning: Failed to load plugins: %v\n", err)
+		fmt.Fprintln(log, "Continuing without plugin defaults...")
+	} else {
+		fmt.Fprintf(log, "Loaded %d hardware plugins: %v\n", len(pluginManager.ListPlugins()), pluginManager.ListPlugins())
+		if err := pluginManager.MergeUserConfigWithDefaults(config); err != nil {
+			return nil, fmt.Errorf("applying plugin defaults: %w", err)
+		}
+		fmt.Fprintln(log, "Successfully applied hardware plugin defaults")
+		loaded.Plugins = pluginManager
+	}
+
+	// Validate, reporting every finding at once
+	loaded.Findings = append(loaded.Findings, config.Findings(loaded.Plugins)...)
+	loaded.Findings.Locate(root)
+	loaded.Findings, loaded.Suppressed = loaded.Findings.Suppress(root, config.Lint)
+	loaded.Findings.Sort()
+
+	return loaded, nil
+}
+
+// discoverClockIDs discovers the clock IDs omitted by subsystems, querying the DPLL netlink family
+// if it is available and the MAC addresses under SysfsRoot otherwise. If discovery is disabled, the
+// omitted clock IDs are reported instead.
+func discoverClockIDs(config *ClockChain, enabled bool) ValidationErrors {
+	var vc validationCollector
+	for si, subsystem := range config.Structure {
+		if subsystem.DPLL.ClockID == 0 {
+			vc.info(RuleClockIDDiscovery, pathIndex("structure", si)+".dpll",
+				"subsystem %s has no clock ID, use --discover to discover it from its first Ethernet port", subsystem.Name)
+		}
+	}
+	if !enabled || len(vc.errs) == 0 {
+		return vc.errs
+	}
+	discovery := &ClockIDDiscovery{SysfsRoot: SysfsRoot}
+	if backend, err := NewNetlinkDPLL(); err == nil {
+		defer backend.Close()
+		discovery.Backend = backend
+	}
+	return config.DiscoverClockIDs(discovery)
+}
+
+// loadConfigSchema loads the schema override set by SchemaFile, or the built-in specification
+func loadConfigSchema() (*OpenAPISchema, error) {
+	if SchemaFile != "" {
+		return LoadSchema(SchemaFile)
+	}
+	return DefaultSchema()
+}
+
+// addLoadFlags registers the flags controlling how a command loads its configuration. Commands that
+// work on the live hardware discover omitted clock IDs by default.
+func addLoadFlags(flags *flag.FlagSet, discover bool) {
+	flags.StringVar(&SchemaFile, "schema", SchemaFile, "OpenAPI specification to validate against (default: the built-in ptp-hw.yaml)")
+	flags.BoolVar(&DiscoverClockIDs, "discover", discover, "discover omitted clock IDs from the DPLL netlink family and sysfs")
+	flags.StringVar(&SysfsRoot, "sysfs-root", SysfsRoot, "root of the sysfs tree interface MAC addresses are read from")
+	flags.Var(&CanonicalClockIDFormat, "clock-id-format", "form clock IDs are normalized to and written in: hex or decimal")
+}
+
+// addReportFlags registers the --fail-on and --min-severity flags of a command, failing on errors
+// and reporting warnings by default
+func addReportFlags(flags *flag.FlagSet) *ReportOptions {
+	return (&ReportOptions{FailOn: SeverityError, MinSeverity: SeverityWarning}).register(flags)
+}
+
+// register registers the --fail-on and --min-severity flags, with the current options as defaults
+func (opts *ReportOptions) register(flags *flag.FlagSet) *ReportOptions {
+	flags.Var(&opts.FailOn, "fail-on", "least serious finding severity that fails the run: error, warning or info")
+	flags.Var(&opts.MinSeverity, "min-severity", "least serious finding severity that is reported: error, warning or info")
+	return opts
+}
+
+// reportFindings prints the findings of a loaded configuration and reports whether any of them is
+// serious enough to fail the command
+func (lc *LoadedConfig) reportFindings(w io.Writer, opts *ReportOptions) bool {
+	shown := opts.MinSeverity
+	if !opts.FailOn.AtLeast(shown) {
+		shown = opts.FailOn
+	}
+	findings := lc.Findings.Filter(shown)
+	if len(findings) > 0 {
+		fmt.Fprintf(w, "Validation found %d problem(s) in %s:\n", len(findings), lc.Path)
+		for _, finding := range findings {
+			fmt.Fprintf(w, "  %v\n", finding)
+		}
+	}
+	if hidden := len(lc.Findings) - len(findings); hidden > 0 || len(lc.Suppressed) > 0 {
+		fmt.Fprintf(w, "%d finding(s) below %s not shown, %d suppressed\n", hidden, shown, len(lc.Suppressed))
+	}
+	return lc.Findings.HasSeverity(opts.FailOn)
+}
+
+// loadForCommand loads a configuration for a subcommand, printing findings to stderr. It returns
+// nil if the configuration cannot be used.
+func loadForCommand(path string, opts *ReportOptions) *LoadedConfig {
+	loaded, err := LoadConfig(path, io.Discard)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return nil
+	}
+	if loaded.reportFindings(os.Stderr, opts) {
+		return nil
+	}
+	return loaded
+}
+
+// subcommandNames returns the names of the subcommands, sorted
+func subcommandNames() []string {
+	names := make([]string, 0, len(subcommands))
+	for name := range subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// writeOutput writes a command result to the named file, or to stdout if the name is empty or "-"
+func writeOutput(path, content string) error {
+	if path == "" || path == "-" {
+		_, err := io.WriteString(os.Stdout, content)
+		return err
+	}
+	return os.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CoverageFormat selects how a coverage truth table is printed
+type CoverageFormat string
+
+const (
+	CoverageFormatMarkdown CoverageFormat = "markdown"
+	CoverageFormatCSV      CoverageFormat = "csv"
+	CoverageFormatJSON     CoverageFormat = "json"
+)
+
+// Coverage is the truth table of a behavior section: the conditions that apply in every
+// combination of locked and lost sources, and the resulting pin tables
+type Coverage struct {
+	// Sources are the names of the behavior sources in priority order
+	Sources []string `json:"sources"`
+
+	// Rows are the rows of the truth table, starting with all sources lost. A combination in which
+	// several conditions can fire has a row for each of them.
+	Rows []CoverageRow `json:"rows"`
+
+	// Combinations is the number of source combinations
+	Combinations int `json:"combinations"`
+
+	// Uncovered is the number of combinations in which no condition fires. The default and init
+	// conditions do not cover the combination with all sources lost, as they only run on profile (re)load.
+	Uncovered int `json:"uncovered"`
+
+	// Pins are the pins whose settings differ from the initial pin table in at least one row. Only
+	// these pins are shown in the Markdown and CSV formats.
+	Pins []PinKey `json:"-"`
+}
+
+// CoverageRow is a source combination together with a condition that applies in it
+type CoverageRow struct {
+	// States is the source combination
+	States SourceCombination `json:"states"`
+
+	// Condition is the name of the condition, or empty if no condition fires in the combination and
+	// the pins keep whatever state they were last in
+	Condition string `json:"condition,omitempty"`
+
+	// Events are the source events leading into the combination that fire Condition. Events that
+	// only fire it when the source was the active one are marked as failover.
+	Events []CombinationOutcome `json:"events,omitempty"`
+
+	// Pins is the pin table in the condition, the initial pin table with the condition applied. It is
+	// not the accumulated state of a run: pins the condition does not set keep whatever state earlier
+	// conditions left them in, which depends on the order of the events.
+	Pins []CoveragePin `json:"pins,omitempty"`
+
+	table PinTable
+}
+
+// Covered reports whether a condition fires in the combination of the row
+func (r *CoverageRow) Covered() bool {
+	return r.Condition != ""
+}
+
+// CoveragePin is the EEC and PPS setting of a pin in a row of the truth table
+type CoveragePin struct {
+	ClockID    ClockID `json:"clockId"`
+	BoardLabel string  `json:"boardLabel"`
+	EEC        string  `json:"eec"`
+	PPS        string  `json:"pps"`
+}
+
+// BuildCoverage builds the truth table of the behavior section of a merged clock chain, based on
+// the conditions AnalyzeConditions finds for every source combination
+func BuildCoverage(cc *ClockChain) (*Coverage, error) {
+	analysis, err := AnalyzeConditions(cc)
+	if err != nil {
+		return nil, err
+	}
+	coverage := &Coverage{Sources: analysis.Sources, Combinations: len(analysis.Combinations)}
+	initial := cc.InitialPinTable()
+	changed := make(PinTable)
+	for _, combination := range analysis.Combinations {
+		if len(combination.conditions) == 0 {
+			coverage.Uncovered++
+			coverage.Rows = append(coverage.Rows, CoverageRow{States: combination.States})
+			continue
+		}
+		for _, i := range combination.conditions {
+			condition := &cc.Behavior.Conditions[i]
+			row := CoverageRow{States: combination.States, Condition: condition.Name, table: cc.ConditionPinTable(condition)}
+			for _, outcome := range combination.Outcomes {
+				if outcome.condition == i {
+					row.Events = append(row.Events, outcome)
+				}
+			}
+			row.setPins()
+			for _, change := range initial.Diff(row.table) {
+				changed[change.Key] = PinSettings{}
+			}
+			coverage.Rows = append(coverage.Rows, row)
+		}
+	}
+	coverage.Pins = changed.Keys()
+	return coverage, nil
+}
+
+// setPins fills the pins of the row from its pin table
+func (r *CoverageRow) setPins() {
+	for _, key := range r.table.Keys() {
+		settings := r.table[key]
+		r.Pins = append(r.Pins, CoveragePin{
+			ClockID:    key.ClockID,
+			BoardLabel: key.BoardLabel,
+			EEC:        settings.EEC.String(),
+			PPS:        settings.PPS.String(),
+		})
+	}
+}
+
+// Format renders the truth table as Markdown, CSV or JSON
+func (c *Coverage) Format(format CoverageFormat) (string, error) {
+	switch format {
+	case CoverageFormatMarkdown, "":
+		return c.markdown(), nil
+	case CoverageFormatCSV:
+		return c.csv()
+	case CoverageFormatJSON:
+		data, err := json.MarshalIndent(c, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+	return "", fmt.Errorf("unknown coverage format %q (supported: %s, %s, %s)", format, CoverageFormatMarkdown, CoverageFormatCSV, CoverageFormatJSON)
+}
+
+// markdown renders the truth table as a Markdown table with a column per source and per changed pin
+func (c *Coverage) markdown() string {
+	var sb strings.Builder
+	header := append([]string(nil), c.Sources...)
+	header = append(header, "Condition", "Last event")
+	for _, key := range c.Pins {
+		header = append(header, key.String())
+	}
+	writeMarkdownRow(&sb, header)
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	writeMarkdownRow(&sb, separator)
+
+	for _, row := range c.Rows {
+		cells := make([]string, 0, len(header))
+		for _, state := range row.States {
+			cells = append(cells, string(state.Status))
+		}
+		if !row.Covered() {
+			cells = append(cells, "**uncovered**", "-")
+			for range c.Pins {
+				cells = append(cells, "unchanged")
+			}
+			writeMarkdownRow(&sb, cells)
+			continue
+		}
+		cells = append(cells, row.Condition, row.events())
+		for _, key := range c.Pins {
+			settings := row.table[key]
+			cells = append(cells, settings.EEC.String()+" / "+settings.PPS.String())
+		}
+		writeMarkdownRow(&sb, cells)
+	}
+
+	fmt.Fprintf(&sb, "\nPin settings are shown as EEC / PPS, for the initial pin table with the condition of the row applied. "+
+		"At runtime, pins a condition does not set keep whatever state earlier conditions left them in. "+
+		"%d of %d combination(s) covered.\n", c.Combinations-c.Uncovered, c.Combinations)
+	return sb.String()
+}
+
+// writeMarkdownRow writes a Markdown table row, escaping pipes in the cells
+func writeMarkdownRow(sb *strings.Builder, cells []string) {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	fmt.Fprintf(sb, "| %s |\n", strings.Join(escaped, " | "))
+}
+
+// csv renders the truth table as CSV with a column per source and per DPLL of every changed pin.
+// The pin columns of uncovered rows are empty.
+func (c *Coverage) csv() (string, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := append([]string(nil), c.Sources...)
+	header = append(header, "condition", "covered", "events")
+	for _, key := range c.Pins {
+		header = append(header, key.String()+" eec", key.String()+" pps")
+	}
+	if err := w.Write(header); err != nil {
+		return "", err
+	}
+	for _, row := range c.Rows {
+		record := make([]string, 0, len(header))
+		for _, state := range row.States {
+			record = append(record, string(state.Status))
+		}
+		record = append(record, row.Condition, fmt.Sprint(row.Covered()), row.events())
+		for _, key := range c.Pins {
+			if !row.Covered() {
+				record = append(record, "", "")
+				continue
+			}
+			settings := row.table[key]
+			record = append(record, settings.EEC.String(), settings.PPS.String())
+		}
+		if err := w.Write(record); err != nil {
+			return "", err
+		}
+	}
+	w.Flush()
+	return buf.String(), w.Error()
+}
+
+// events formats the events firing the condition of a row, e.g. "GNSS lost or Ethernet lost (failover)"
+func (r *CoverageRow) events() string {
+	events := make([]string, len(r.Events))
+	for i, outcome := range r.Events {
+		events[i] = outcome.Event.String()
+		if outcome.Failover {
+			events[i] += " (failover)"
+		}
+	}
+	return strings.Join(events, " or ")
+}
+
+// runCoverage implements the coverage subcommand. It exits with 1 if any source combination is not
+// covered by a condition.
+func runCoverage(args []string) int {
+	flags := flag.NewFlagSet("coverage", flag.ContinueOnError)
+	format := flags.String("format", string(CoverageFormatMarkdown), "output format: markdown, csv or json")
+	output := flags.String("o", "", "output file (default: stdout)")
+	report := addReportFlags(flags)
+	addLoadFlags(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser coverage [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded := loadForCommand(flags.Arg(0), report)
+	if loaded == nil {
+		return 1
+	}
+	coverage, err := BuildCoverage(loaded.Config)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	rendered, err := coverage.Format(CoverageFormat(*format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := writeOutput(*output, rendered); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	if coverage.Uncovered > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d source combination(s) are not covered by any condition\n", coverage.Uncovered, coverage.Combinations)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SysfsRoot is the root of the sysfs tree network interface addresses are read from
+var SysfsRoot = "/sys"
+
+// interfacePinResolver is implemented by DPLL backends that can look up the DPLL pin of a network
+// interface, e.g. the recovered clock pin the netdev reports through rtnetlink
+type interfacePinResolver interface {
+	InterfacePinID(iface string) (uint32, error)
+}
+
+// ClockIDDiscovery derives the clock IDs of subsystems from their first Ethernet port
+type ClockIDDiscovery struct {
+	// SysfsRoot is the root of the sysfs tree, "/sys" if empty
+	SysfsRoot string
+
+	// Backend is an optional DPLL backend. If it can look up the DPLL pin of the port, the clock ID
+	// of the pin is used. Otherwise the clock ID derived from the port MAC must match one of its devices.
+	Backend DPLLBackend
+}
+
+// Discover returns the clock ID of the DPLL a network interface belongs to. The DPLL pin of
+// the interface is queried from the backend if possible; otherwise the clock ID is the EUI-64 of the
+// interface MAC address read from sysfs, the form NIC drivers use to register their DPLLs.
+func (d *ClockIDDiscovery) Discover(port string) (ClockID, error) {
+	if resolver, ok := d.Backend.(interfacePinResolver); ok {
+		if pinID, err := resolver.InterfacePinID(port); err == nil {
+			pins, err := d.Backend.Pins()
+			if err != nil {
+				return 0, fmt.Errorf("listing DPLL pins: %w", err)
+			}
+			for _, pin := range pins {
+				if pin.ID == pinID {
+					return pin.ClockID, nil
+				}
+			}
+			return 0, fmt.Errorf("DPLL pin %d of interface %s not found", pinID, port)
+		}
+	}
+
+	mac, err := readInterfaceMAC(d.SysfsRoot, port)
+	if err != nil {
+		return 0, err
+	}
+	clockID, err := eui64FromMAC(mac)
+	if err != nil {
+		return 0, fmt.Errorf("interface %s: %w", port, err)
+	}
+
+	if d.Backend != nil {
+		devices, err := d.Backend.Devices()
+		if err != nil {
+			return 0, fmt.Errorf("listing DPLL devices: %w", err)
+		}
+		for _, device := range devices {
+			if device.ClockID == clockID {
+				return clockID, nil
+			}
+		}
+		return 0, fmt.Errorf("no DPLL device with clock ID %s derived from the MAC address of interface %s", clockID, port)
+	}
+	return clockID, nil
+}
+
+// DiscoverClockIDs sets the clock ID of every subsystem that omits it to the clock ID discovered
+// from its first Ethernet port. Subsystems whose clock ID cannot be discovered are reported as
+// warnings and keep a zero clock ID.
+func (cc *ClockChain) DiscoverClockIDs(d *ClockIDDiscovery) ValidationErrors {
+	var vc validationCollector
+	for si := range cc.Structure {
+		subsystem := &cc.Structure[si]
+		if subsystem.DPLL.ClockID != 0 {
+			continue
+		}
+		path := pathIndex("structure", si) + ".dpll"
+		if len(subsystem.Ethernet) == 0 || len(subsystem.Ethernet[0].Ports) == 0 {
+			vc.warn(RuleClockIDDiscovery, path, "subsystem %s has no clock ID and no Ethernet port to discover it from", subsystem.Name)
+			continue
+		}
+		port := subsystem.Ethernet[0].Ports[0]
+		clockID, err := d.Discover(port)
+		if err != nil {
+			vc.warn(RuleClockIDDiscovery, path, "clock ID of subsystem %s could not be discovered from port %s: %v", subsystem.Name, port, err)
+			continue
+		}
+		subsystem.DPLL.ClockID = clockID
+	}
+	return vc.errs
+}
+
+// readInterfaceMAC reads the MAC address of a network interface from sysfs
+func readInterfaceMAC(root, iface string) (net.HardwareAddr, error) {
+	if root == "" {
+		root = "/sys"
+	}
+	if iface == "" || strings.ContainsAny(iface, "/\x00") || iface == "." || iface == ".." {
+		return nil, fmt.Errorf("invalid interface name %q", iface)
+	}
+	data, err := os.ReadFile(filepath.Join(root, "class", "net", iface, "address"))
+	if err != nil {
+		return nil, fmt.Errorf("reading MAC address of interface %s: %w", iface, err)
+	}
+	mac, err := net.ParseMAC(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("interface %s: %w", iface, err)
+	}
+	return mac, nil
+}
+
+// eui64FromMAC derives the EUI-64 of a 48-bit MAC address by inserting 0xfffe in the middle, e.g.
+// 11:22:33:44:55:66 becomes 0x112233fffe445566
+func eui64FromMAC(mac net.HardwareAddr) (ClockID, error) {
+	if len(mac) != 6 {
+		return 0, fmt.Errorf("MAC address %s is not a 48-bit address", mac)
+	}
+	var clockID ClockID
+	for _, b := range []byte{mac[0], mac[1], mac[2], 0xff, 0xfe, mac[3], mac[4], mac[5]} {
+		clockID = clockID<<8 | ClockID(b)
+	}
+	return clockID, nil
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// DPLLType is the type of a DPLL device. Each subsystem clock has an EEC and a PPS DPLL.
+type DPLLType string
+
+const (
+	DPLLTypeEEC DPLLType = "eec"
+	DPLLTypePPS DPLLType = "pps"
+)
+
+// DPLLDevice is a DPLL device as reported by the driver
+type DPLLDevice struct {
+	ID         uint32   `json:"id"`
+	ModuleName string   `json:"moduleName"`
+	ClockID    ClockID  `json:"clockId"`
+	Type       DPLLType `json:"type"`
+	LockStatus string   `json:"lockStatus"`
+}
+
+// DPLLPinParent is the relation of a pin to one of the DPLL devices it is connected to
+type DPLLPinParent struct {
+	DeviceID  uint32       `json:"parentId"`
+	Direction PinDirection `json:"direction"`
+	Priority  *uint32      `json:"prio,omitempty"`
+	State     string       `json:"state"`
+}
+
+// DPLLPin is a DPLL pin as reported by the driver
+type DPLLPin struct {
+	ID             uint32          `json:"id"`
+	ModuleName     string          `json:"moduleName"`
+	ClockID        ClockID         `json:"clockId"`
+	BoardLabel     string          `json:"boardLabel"`
+	Frequency      uint64          `json:"frequency"`
+	PhaseAdjust    int32           `json:"phaseAdjust"`
+	ESyncFrequency uint64          `json:"esyncFrequency,omitempty"`
+	Parents        []DPLLPinParent `json:"parentDevices"`
+}
+
+// DPLLPinSet is a pin-set request. Fields left nil are not changed. Priority and State apply to
+// the relation with the ParentDevice.
+type DPLLPinSet struct {
+	PinID          uint32
+	Frequency      *uint64
+	PhaseAdjust    *int32
+	ESyncFrequency *uint64
+	ParentDevice   *uint32
+	Priority       *uint32
+	State          string
+}
+
+// DPLLBackend queries and configures DPLL devices and pins, e.g. through the Linux DPLL generic
+// netlink family or an in-memory fake
+type DPLLBackend interface {
+	// Devices returns all DPLL devices
+	Devices() ([]DPLLDevice, error)
+
+	// Pins returns all DPLL pins
+	Pins() ([]DPLLPin, error)
+
+	// SetPin applies a pin-set request
+	SetPin(request DPLLPinSet) error
+
+	// Close releases the backend resources
+	Close() error
+}
+
+// PinOperation is a hardware independent pin-set operation on a pin identified by clock ID and
+// board label. Priority and State apply to the DPLL of the given type; the other settings apply to
+// the pin itself and leave DPLLType empty.
+type PinOperation struct {
+	ClockID    ClockID  `json:"clockId"`
+	BoardLabel string   `json:"boardLabel"`
+	DPLLType   DPLLType `json:"dpllType,omitempty"`
+
+	Priority       *uint32 `json:"priority,omitempty"`
+	State          string  `json:"state,omitempty"`
+	Frequency      *uint64 `json:"frequency,omitempty"`
+	PhaseAdjust    *int32  `json:"phaseAdjust,omitempty"`
+	ESyncFrequency *uint64 `json:"esyncFrequency,omitempty"`
+}
+
+func (op PinOperation) String() string {
+	s := fmt.Sprintf("pin-set clock %s label %s", op.ClockID, op.BoardLabel)
+	if op.DPLLType != "" {
+		s += " dpll-type " + string(op.DPLLType)
+	}
+	if settings := op.settings(); len(settings) > 0 {
+		s += " " + strings.Join(settings, " ")
+	}
+	return s
+}
+
+// DesiredStateOperations converts desired states into pin operations, preserving their order.
+// For each desired state the EEC operation precedes the PPS operation.
+func DesiredStateOperations(states []DesiredState) ([]PinOperation, error) {
+	var ops []PinOperation
+	for i, ds := range states {
+		for _, dpll := range []struct {
+			dpllType DPLLType
+			state    *PinState
+		}{{DPLLTypeEEC, ds.EEC}, {DPLLTypePPS, ds.PPS}} {
+			if dpll.state == nil || (dpll.state.Priority == nil && dpll.state.State == "") {
+				continue
+			}
+			op := PinOperation{ClockID: ds.ClockID, BoardLabel: ds.BoardLabel, DPLLType: dpll.dpllType, State: string(dpll.state.State)}
+			if dpll.state.Priority != nil {
+				priority := *dpll.state.Priority
+				if priority < 0 || priority > math.MaxUint32 || priority != math.Trunc(priority) {
+					return nil, fmt.Errorf("desired state %d (%s %s): invalid %s priority %g",
+						i, ds.ClockID, ds.BoardLabel, dpll.dpllType, priority)
+				}
+				value := uint32(priority)
+				op.Priority = &value
+			}
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// TableOperations converts the settings of a pin table into pin operations, ordered by pin. For each
+// pin the EEC operation precedes the PPS operation.
+func TableOperations(table PinTable) ([]PinOperation, error) {
+	var states []DesiredState
+	for _, key := range table.Keys() {
+		settings := table[key]
+		eec, pps := settings.EEC, settings.PPS
+		states = append(states, DesiredState{ClockID: key.ClockID, BoardLabel: key.BoardLabel, EEC: &eec, PPS: &pps})
+	}
+	return DesiredStateOperations(states)
+}
+
+// PinConfigOperations converts the frequency, phase adjustment and eSync settings of the pins
+// declared in the DPLL pin maps into pin operations, ordered by subsystem and board label.
+// Subsystems without a clock ID are skipped. Input pins are compensated by the sum of the internal
+// and external phase adjustments, output pins by the internal adjustment.
+func PinConfigOperations(cc *ClockChain) ([]PinOperation, error) {
+	esyncConfigs := make(map[string]ESyncConfig)
+	if cc.CommonDefinitions != nil {
+		for _, def := range cc.CommonDefinitions.ESyncDefinitions {
+			esyncConfigs[def.Name] = def.ESyncConfig.withDefaults()
+		}
+	}
+
+	var ops []PinOperation
+	for _, pin := range declaredPins(cc, NewPinIndex(cc, nil)) {
+		config := pin.Config
+		op := PinOperation{ClockID: pin.Subsystem.DPLL.ClockID, BoardLabel: pin.BoardLabel}
+
+		if config.ESyncConfigName != "" {
+			esync, ok := esyncConfigs[config.ESyncConfigName]
+			if !ok {
+				return nil, fmt.Errorf("pin %s: eSync config %s not found", pin, config.ESyncConfigName)
+			}
+			transfer, embedded := uint64(esync.TransferFrequency), uint64(esync.EmbeddedSyncFrequency)
+			op.Frequency = &transfer
+			op.ESyncFrequency = &embedded
+		} else if config.Frequency != nil {
+			frequency := uint64(*config.Frequency)
+			op.Frequency = &frequency
+		}
+
+		if adjustment := config.PhaseAdjustment; adjustment != nil {
+			total := adjustment.Internal
+			if pin.Direction == PinDirectionInput && adjustment.External != nil {
+				total += *adjustment.External
+			}
+			if total < math.MinInt32 || total > math.MaxInt32 {
+				return nil, fmt.Errorf("pin %s: phase adjustment %d ps is out of range", pin, total)
+			}
+			phaseAdjust := int32(total)
+			op.PhaseAdjust = &phaseAdjust
+		}
+
+		if op.Frequency != nil || op.PhaseAdjust != nil {
+			ops = append(ops, op)
+		}
+	}
+	return ops, nil
+}
+
+// resolvePinOperation translates a pin operation into a pin-set request, using the devices and
+// pins reported by the backend
+func resolvePinOperation(op PinOperation, devices []DPLLDevice, pins []DPLLPin) (DPLLPinSet, error) {
+	clockID := op.ClockID
+	request := DPLLPinSet{
+		Frequency:      op.Frequency,
+		PhaseAdjust:    op.PhaseAdjust,
+		ESyncFrequency: op.ESyncFrequency,
+		Priority:       op.Priority,
+		State:          op.State,
+	}
+
+	found := false
+	for _, pin := range pins {
+		if pin.ClockID == clockID && pin.BoardLabel == op.BoardLabel {
+			request.PinID = pin.ID
+			found = true
+			break
+		}
+	}
+	if !found {
+		return DPLLPinSet{}, fmt.Errorf("no DPLL pin with clock ID %s and board label %s", op.ClockID, op.BoardLabel)
+	}
+
+	if op.DPLLType != "" {
+		for _, device := range devices {
+			if device.ClockID == clockID && device.Type == op.DPLLType {
+				id := device.ID
+				request.ParentDevice = &id
+				break
+			}
+		}
+		if request.ParentDevice == nil {
+			return DPLLPinSet{}, fmt.Errorf("no %s DPLL device with clock ID %s", op.DPLLType, op.ClockID)
+		}
+	}
+	return request, nil
+}
+
+// ApplyOperations resolves pin operations against the devices and pins of a backend and applies
+// them in order. It stops at the first failing operation.
+func ApplyOperations(backend DPLLBackend, ops []PinOperation) error {
+	devices, err := backend.Devices()
+	if err != nil {
+		return fmt.Errorf("listing DPLL devices: %w", err)
+	}
+	pins, err := backend.Pins()
+	if err != nil {
+		return fmt.Errorf("listing DPLL pins: %w", err)
+	}
+
+	requests := make([]DPLLPinSet, len(ops))
+	for i, op := range ops {
+		if requests[i], err = resolvePinOperation(op, devices, pins); err != nil {
+			return fmt.Errorf("%v: %w", op, err)
+		}
+	}
+	for i, request := range requests {
+		if err := backend.SetPin(request); err != nil {
+			return fmt.Errorf("%v: %w", ops[i], err)
+		}
+	}
+	return nil
+}
+
+// ApplyCondition configures the pins of a merged clock chain and applies the desired states of a
+// condition through a backend
+func ApplyCondition(backend DPLLBackend, cc *ClockChain, condition *Condition) error {
+	ops, err := PinConfigOperations(cc)
+	if err != nil {
+		return err
+	}
+	stateOps, err := DesiredStateOperations(condition.DesiredStates)
+	if err != nil {
+		return err
+	}
+	return ApplyOperations(backend, append(ops, stateOps...))
+}
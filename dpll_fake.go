@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// FakeDPLL is an in-memory DPLLBackend. Pin-set requests are recorded and applied to its pins,
+// so that configurations can be applied and read back without hardware.
+type FakeDPLL struct {
+	devices []DPLLDevice
+	pins    []DPLLPin
+
+	// Requests are the pin-set requests received, in order
+	Requests []DPLLPinSet
+
+	// InterfacePins maps network interfaces to the IDs of their DPLL pins
+	InterfacePins map[string]uint32
+}
+
+// NewFakeDPLL creates a fake backend with the given devices and pins
+func NewFakeDPLL(devices []DPLLDevice, pins []DPLLPin) *FakeDPLL {
+	f := &FakeDPLL{devices: append([]DPLLDevice(nil), devices...)}
+	for _, pin := range pins {
+		f.pins = append(f.pins, pin.clone())
+	}
+	return f
+}
+
+// NewFakeDPLLForConfig creates a fake backend modeling a clock chain: an EEC and a PPS device for each
+// subsystem with a clock ID, and a pin for each declared pin, connected to both devices
+func NewFakeDPLLForConfig(cc *ClockChain) (*FakeDPLL, error) {
+	f := &FakeDPLL{}
+	deviceIDs := make(map[ClockID][2]uint32)
+	for _, subsystem := range cc.Structure {
+		clockID := subsystem.DPLL.ClockID
+		if clockID == 0 {
+			continue
+		}
+		if _, exists := deviceIDs[clockID]; exists {
+			continue
+		}
+		eec, pps := uint32(len(f.devices)), uint32(len(f.devices)+1)
+		deviceIDs[clockID] = [2]uint32{eec, pps}
+		f.devices = append(f.devices,
+			DPLLDevice{ID: eec, ModuleName: "fake", ClockID: clockID, Type: DPLLTypeEEC, LockStatus: "unlocked"},
+			DPLLDevice{ID: pps, ModuleName: "fake", ClockID: clockID, Type: DPLLTypePPS, LockStatus: "unlocked"})
+	}
+
+	for _, pin := range declaredPins(cc, NewPinIndex(cc, nil)) {
+		clockID := pin.Subsystem.DPLL.ClockID
+		fakePin := DPLLPin{ID: uint32(len(f.pins)), ModuleName: "fake", ClockID: clockID, BoardLabel: pin.BoardLabel}
+		for _, deviceID := range deviceIDs[clockID] {
+			parent := DPLLPinParent{DeviceID: deviceID, Direction: pin.Direction, State: "disconnected"}
+			if pin.Direction == PinDirectionInput {
+				priority := uint32(DisabledPriority)
+				parent.Priority = &priority
+				parent.State = "selectable"
+			}
+			fakePin.Parents = append(fakePin.Parents, parent)
+		}
+		f.pins = append(f.pins, fakePin)
+	}
+	return f, nil
+}
+
+// DPLLFixture is a recorded snapshot of the devices and pins of a DPLL backend
+type DPLLFixture struct {
+	Devices []DPLLDevice `json:"devices"`
+	Pins    []DPLLPin    `json:"pins"`
+}
+
+// LoadDPLLFixture creates a fake backend from a fixture file recorded with RecordDPLLFixture
+func LoadDPLLFixture(path string) (*FakeDPLL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DPLL fixture: %w", err)
+	}
+	var fixture DPLLFixture
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return nil, fmt.Errorf("failed to parse DPLL fixture %s: %w", path, err)
+	}
+	return NewFakeDPLL(fixture.Devices, fixture.Pins), nil
+}
+
+// RecordDPLLFixture writes the devices and pins reported by a backend to a fixture file
+func RecordDPLLFixture(backend DPLLBackend, path string) error {
+	var fixture DPLLFixture
+	var err error
+	if fixture.Devices, err = backend.Devices(); err != nil {
+		return fmt.Errorf("listing DPLL devices: %w", err)
+	}
+	if fixture.Pins, err = backend.Pins(); err != nil {
+		return fmt.Errorf("listing DPLL pins: %w", err)
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Devices implements DPLLBackend
+func (f *FakeDPLL) Devices() ([]DPLLDevice, error) {
+	return append([]DPLLDevice(nil), f.devices...), nil
+}
+
+// Pins implements DPLLBackend
+func (f *FakeDPLL) Pins() ([]DPLLPin, error) {
+	pins := make([]DPLLPin, len(f.pins))
+	for i, pin := range f.pins {
+		pins[i] = pin.clone()
+	}
+	return pins, nil
+}
+
+// SetPin implements DPLLBackend. Requests are validated the way the netlink backend encodes them.
+func (f *FakeDPLL) SetPin(request DPLLPinSet) error {
+	if _, err := encodeDPLLPinSet(request); err != nil {
+		return err
+	}
+	f.Requests = append(f.Requests, request)
+
+	for i := range f.pins {
+		pin := &f.pins[i]
+		if pin.ID != request.PinID {
+			continue
+		}
+		if request.ParentDevice != nil {
+			var parent *DPLLPinParent
+			for pi := range pin.Parents {
+				if pin.Parents[pi].DeviceID == *request.ParentDevice {
+					parent = &pin.Parents[pi]
+				}
+			}
+			if parent == nil {
+				return fmt.Errorf("pin %d is not connected to DPLL device %d", pin.ID, *request.ParentDevice)
+			}
+			if request.Priority != nil {
+				priority := *request.Priority
+				parent.Priority = &priority
+			}
+			if request.State != "" {
+				parent.State = request.State
+			}
+		}
+		if request.Frequency != nil {
+			pin.Frequency = *request.Frequency
+		}
+		if request.PhaseAdjust != nil {
+			pin.PhaseAdjust = *request.PhaseAdjust
+		}
+		if request.ESyncFrequency != nil {
+			pin.ESyncFrequency = *request.ESyncFrequency
+		}
+		return nil
+	}
+	return fmt.Errorf("no DPLL pin with ID %d", request.PinID)
+}
+
+// SetLockStatus changes the lock status reported for a device
+func (f *FakeDPLL) SetLockStatus(deviceID uint32, status string) {
+	for i := range f.devices {
+		if f.devices[i].ID == deviceID {
+			f.devices[i].LockStatus = status
+		}
+	}
+}
+
+// InterfacePinID returns the DPLL pin of a network interface from InterfacePins
+func (f *FakeDPLL) InterfacePinID(iface string) (uint32, error) {
+	if pinID, ok := f.InterfacePins[iface]; ok {
+		return pinID, nil
+	}
+	return 0, fmt.Errorf("interface %s has no DPLL pin", iface)
+}
+
+// Close implements DPLLBackend
+func (f *FakeDPLL) Close() error {
+	return nil
+}
+
+// clone returns a deep copy of the pin
+func (p DPLLPin) clone() DPLLPin {
+	parents := make([]DPLLPinParent, len(p.Parents))
+	for i, parent := range p.Parents {
+		if parent.Priority != nil {
+			priority := *parent.Priority
+			parent.Priority = &priority
+		}
+		parents[i] = parent
+	}
+	p.Parents = parents
+	return p
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SourceStatus is the observed state of a behavior source
+type SourceStatus string
+
+const (
+	SourceLocked SourceStatus = "locked"
+	SourceLost   SourceStatus = "lost"
+)
+
+// SourceEvent reports a transition of a behavior source
+type SourceEvent struct {
+	// Source is the name of the source in Behavior.Sources
+	Source string `json:"source"`
+
+	// Status is the new source state
+	Status SourceStatus `json:"status"`
+}
+
+func (e SourceEvent) String() string {
+	return e.Source + " " + string(e.Status)
+}
+
+// EngineStep is the outcome of handling one source event
+type EngineStep struct {
+	// Event is the handled event
+	Event SourceEvent
+
+	// Condition is the condition that fired, or nil if no condition applies and the pins keep their state
+	Condition *Condition
+
+	// Candidates are all conditions that matched the source states when the event was handled, in the
+	// order they are listed. Condition is the first of them.
+	Candidates []*Condition
+
+	// Failover is set when the active source was lost and Condition was selected for the locked
+	// source with the highest priority instead
+	Failover bool
+
+	// ActiveSource is the source disciplining the clock chain after the event, or empty if none is locked
+	ActiveSource string
+
+	// Holdover is set when the active source was lost and no other source is locked. The subsystem of
+	// HoldoverSource may then enter holdover, and the other subsystems follow it.
+	Holdover       bool
+	HoldoverSource string
+
+	// Changes are the pin settings changed by the condition
+	Changes []PinChange
+
+	// Table is the effective pin table after the event
+	Table PinTable
+}
+
+// Engine evaluates the behavior conditions of a merged clock chain against source events and tracks
+// the resulting pin states, without touching hardware. It implements the semantics documented on
+// ClockChain.Behavior:
+//
+//   - The "default" conditions and then the "init" conditions are applied when the engine starts, and
+//     all sources are considered lost.
+//   - A condition fires when an event for its triggering source (the first entry of Condition.Sources)
+//     matches its condition type, and all supporting conditions, source states or any/all/not
+//     expressions, match the current source states. If several conditions match, the first one listed fires.
+//   - If more than one source is locked, the source with the smaller index has priority: a condition is
+//     not fired while a source listed before its triggering source is locked, unless the condition
+//     states the required state of that source itself, possibly within an expression.
+//   - If the active source is lost and no condition handles the loss, the locked source with the
+//     highest priority takes over through its "locked" conditions. If no other source is locked, the
+//     subsystem of the lost source enters holdover.
+//   - Desired states are applied on top of the current pin states, in the order they are listed.
+type Engine struct {
+	cc          *ClockChain
+	sourceIndex map[string]int
+	status      map[string]SourceStatus
+	active      string
+	condition   *Condition
+	table       PinTable
+}
+
+// NewEngine creates an engine for a merged clock chain and applies its default and init conditions
+func NewEngine(cc *ClockChain) (*Engine, error) {
+	if cc.Behavior == nil {
+		return nil, fmt.Errorf("configuration has no behavior section")
+	}
+	e := &Engine{cc: cc, sourceIndex: make(map[string]int)}
+	for i, source := range cc.Behavior.Sources {
+		if _, exists := e.sourceIndex[source.Name]; exists {
+			return nil, fmt.Errorf("duplicate source name: %s", source.Name)
+		}
+		e.sourceIndex[source.Name] = i
+	}
+	e.Reset()
+	return e, nil
+}
+
+// Reset returns the engine to its initial state: all sources lost and only the default and init
+// conditions applied
+func (e *Engine) Reset() {
+	e.status = make(map[string]SourceStatus, len(e.sourceIndex))
+	for name := range e.sourceIndex {
+		e.status[name] = SourceLost
+	}
+	e.active = ""
+	e.condition = nil
+	e.table = e.cc.InitialPinTable()
+}
+
+// Handle applies a source event and returns the resulting step
+func (e *Engine) Handle(event SourceEvent) (*EngineStep, error) {
+	if _, ok := e.sourceIndex[event.Source]; !ok {
+		return nil, fmt.Errorf("unknown source %q (known: %s)", event.Source, strings.Join(e.SourceNames(), ", "))
+	}
+	if event.Status != SourceLocked && event.Status != SourceLost {
+		return nil, fmt.Errorf("invalid status %q for source %s (expected %s or %s)", event.Status, event.Source, SourceLocked, SourceLost)
+	}
+
+	step := &EngineStep{Event: event}
+	changed := e.status[event.Source] != event.Status
+	e.status[event.Source] = event.Status
+
+	if changed {
+		step.Candidates = e.matching(event.Source, event.Status)
+		if len(step.Candidates) > 0 {
+			step.Condition = step.Candidates[0]
+		}
+
+		if event.Source == e.active && event.Status == SourceLost {
+			e.active = ""
+			if step.Condition == nil {
+				if best := e.bestLocked(); best != "" {
+					step.Candidates = e.matching(best, SourceLocked)
+					if len(step.Candidates) > 0 {
+						step.Condition = step.Candidates[0]
+						step.Failover = true
+					}
+				}
+			}
+			if e.bestLocked() == "" {
+				step.Holdover = true
+				step.HoldoverSource = event.Source
+			}
+		}
+	}
+
+	before := e.table.Clone()
+	if step.Condition != nil {
+		e.condition = step.Condition
+		e.table.Apply(step.Condition.DesiredStates)
+		if trigger := step.Condition.Sources[0]; trigger.ConditionType == ConditionTypeLocked {
+			e.active = trigger.SourceName
+		}
+	}
+	if e.active == "" || e.status[e.active] != SourceLocked {
+		e.active = e.bestLocked()
+	}
+
+	step.ActiveSource = e.active
+	step.Changes = before.Diff(e.table)
+	step.Table = e.table.Clone()
+	return step, nil
+}
+
+// matching returns the conditions triggered by a source entering a state, whose supporting
+// conditions hold and that are not overridden by a locked source with higher priority
+func (e *Engine) matching(source string, status SourceStatus) []*Condition {
+	var matches []*Condition
+	for i := range e.cc.Behavior.Conditions {
+		condition := &e.cc.Behavior.Conditions[i]
+		if len(condition.Sources) == 0 || isDefaultCondition(condition) || isInitCondition(condition) {
+			continue
+		}
+		trigger := condition.Sources[0]
+		if trigger.SourceName != source || trigger.ConditionType != ConditionType(status) {
+			continue
+		}
+		if e.holds(condition) && !e.overridden(condition) {
+			matches = append(matches, condition)
+		}
+	}
+	return matches
+}
+
+// holds reports whether every source state and expression of a condition matches the current source states
+func (e *Engine) holds(condition *Condition) bool {
+	for i := range condition.Sources {
+		if !condition.Sources[i].Holds(e.status) {
+			return false
+		}
+	}
+	return true
+}
+
+// overridden reports whether a locked source listed before the triggering source of a condition,
+// and not mentioned by the condition, takes priority over it
+func (e *Engine) overridden(condition *Condition) bool {
+	triggerIndex := e.sourceIndex[condition.Sources[0].SourceName]
+	mentioned := make(map[string]bool, len(condition.Sources))
+	for i := range condition.Sources {
+		for _, state := range condition.Sources[i].leaves() {
+			mentioned[state.SourceName] = true
+		}
+	}
+	for _, source := range e.cc.Behavior.Sources[:triggerIndex] {
+		if e.status[source.Name] == SourceLocked && !mentioned[source.Name] {
+			return true
+		}
+	}
+	return false
+}
+
+// bestLocked returns the locked source with the smallest index, or an empty string if none is locked
+func (e *Engine) bestLocked() string {
+	for _, source := range e.cc.Behavior.Sources {
+		if e.status[source.Name] == SourceLocked {
+			return source.Name
+		}
+	}
+	return ""
+}
+
+// SourceNames returns the names of the behavior sources in priority order
+func (e *Engine) SourceNames() []string {
+	names := make([]string, len(e.cc.Behavior.Sources))
+	for i, source := range e.cc.Behavior.Sources {
+		names[i] = source.Name
+	}
+	return names
+}
+
+// SourceStatus returns the current state of a source
+func (e *Engine) SourceStatus(source string) SourceStatus {
+	return e.status[source]
+}
+
+// ActiveSource returns the source disciplining the clock chain, or an empty string if none is locked
+func (e *Engine) ActiveSource() string {
+	return e.active
+}
+
+// ActiveCondition returns the condition that fired last, or nil if only the defaults are applied
+func (e *Engine) ActiveCondition() *Condition {
+	return e.condition
+}
+
+// Table returns a copy of the current effective pin table
+func (e *Engine) Table() PinTable {
+	return e.table.Clone()
+}
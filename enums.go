@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SourceType identifies the kind of a time source
+type SourceType string
+
+const (
+	SourceTypePTPTimeReceiver SourceType = "ptpTimeReceiver"
+	SourceTypeGNSS            SourceType = "gnss"
+)
+
+// SourceTypes are the valid source types, as listed in ptp-hw.yaml
+var SourceTypes = []SourceType{SourceTypePTPTimeReceiver, SourceTypeGNSS}
+
+// ConditionType is the state condition of a source in a condition
+type ConditionType string
+
+const (
+	// ConditionTypeDefault conditions initialize the hardware to the manufacturer recommended
+	// defaults on profile (re)load
+	ConditionTypeDefault ConditionType = "default"
+
+	// ConditionTypeInit conditions prepare the hardware for the Acquiring state, e.g. disable GNSS in
+	// boundary clock setups. They are applied after the default conditions and before any locked or
+	// lost condition.
+	ConditionTypeInit ConditionType = "init"
+
+	ConditionTypeLocked ConditionType = "locked"
+	ConditionTypeLost   ConditionType = "lost"
+)
+
+// ConditionTypes are the valid condition types, as listed in ptp-hw.yaml
+var ConditionTypes = []ConditionType{ConditionTypeDefault, ConditionTypeInit, ConditionTypeLocked, ConditionTypeLost}
+
+// PinStateValue is the desired state of a pin in a DPLL
+type PinStateValue string
+
+const (
+	PinStateConnected    PinStateValue = "connected"
+	PinStateDisconnected PinStateValue = "disconnected"
+	PinStateSelectable   PinStateValue = "selectable"
+)
+
+// PinStateValues are the valid pin states, as listed in ptp-hw.yaml
+var PinStateValues = []PinStateValue{PinStateConnected, PinStateDisconnected, PinStateSelectable}
+
+// UnmarshalYAML rejects unknown source types
+func (t *SourceType) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalEnum(node, "sourceType", SourceTypes, t)
+}
+
+// UnmarshalYAML rejects unknown condition types
+func (t *ConditionType) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalEnum(node, "conditionType", ConditionTypes, t)
+}
+
+// UnmarshalYAML rejects unknown pin states
+func (s *PinStateValue) UnmarshalYAML(node *yaml.Node) error {
+	return unmarshalEnum(node, "state", PinStateValues, s)
+}
+
+// MarshalYAML writes the source type as a string
+func (t SourceType) MarshalYAML() (interface{}, error) {
+	return string(t), nil
+}
+
+// MarshalYAML writes the condition type as a string
+func (t ConditionType) MarshalYAML() (interface{}, error) {
+	return string(t), nil
+}
+
+// MarshalYAML writes the pin state as a string
+func (s PinStateValue) MarshalYAML() (interface{}, error) {
+	return string(s), nil
+}
+
+// unmarshalEnum decodes a scalar into one of the valid values of an enum, or fails with the valid
+// values and the closest one
+func unmarshalEnum[T ~string](node *yaml.Node, field string, valid []T, out *T) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return err
+	}
+	names := make([]string, len(valid))
+	for i, v := range valid {
+		if string(v) == value {
+			*out = v
+			return nil
+		}
+		names[i] = string(v)
+	}
+	return fmt.Errorf("line %d: invalid %s %q%s, valid values: %s",
+		node.Line, field, value, didYouMean(value, names), strings.Join(names, ", "))
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GraphFormat selects the diagram language produced by RenderGraph
+type GraphFormat string
+
+const (
+	GraphFormatDOT     GraphFormat = "dot"
+	GraphFormatMermaid GraphFormat = "mermaid"
+)
+
+// GraphOptions controls diagram rendering
+type GraphOptions struct {
+	// Format is the diagram language, GraphFormatDOT if empty
+	Format GraphFormat
+
+	// Condition is the name of a behavior condition. Pins set by its desired states are highlighted
+	// as enabled or disabled.
+	Condition string
+}
+
+// pinHighlight marks a pin set by the desired states of the highlighted condition
+type pinHighlight int
+
+const (
+	highlightNone pinHighlight = iota
+	highlightEnabled
+	highlightDisabled
+)
+
+// graphPin is a pin node of the diagram
+type graphPin struct {
+	id        string
+	pin       *PinInfo
+	sources   []SourceConfig
+	highlight pinHighlight
+}
+
+// graphSubsystem is a subsystem cluster of the diagram
+type graphSubsystem struct {
+	id        string
+	subsystem *Subsystem
+	pins      []*graphPin
+}
+
+// graphEdge is a link of the diagram. From and to are pin node IDs or external node IDs.
+type graphEdge struct {
+	from, to                   string
+	fromConnector, toConnector bool
+	label                      string
+	inferred                   bool
+}
+
+// graphModel is the diagram content shared by the DOT and Mermaid renderers
+type graphModel struct {
+	subsystems []*graphSubsystem
+	externals  [][2]string // node ID and description
+	edges      []graphEdge
+}
+
+// RenderGraph renders the subsystems, pins and links of a merged clock chain as a diagram.
+// Subsystems are drawn as clusters of their DPLL pins, connectors as ports of the pins routed to
+// them, and sources are annotated on the input pins receiving them.
+func RenderGraph(cc *ClockChain, pm *PluginManager, opts GraphOptions) (string, error) {
+	model, err := newGraphModel(cc, pm, opts.Condition)
+	if err != nil {
+		return "", err
+	}
+	switch opts.Format {
+	case GraphFormatDOT, "":
+		return model.dot(), nil
+	case GraphFormatMermaid:
+		return model.mermaid(), nil
+	}
+	return "", fmt.Errorf("unknown graph format %q (supported: %s, %s)", opts.Format, GraphFormatDOT, GraphFormatMermaid)
+}
+
+func newGraphModel(cc *ClockChain, pm *PluginManager, conditionName string) (*graphModel, error) {
+	pins := NewPinIndex(cc, pm)
+
+	// Pin states of the highlighted condition
+	var highlighted PinTable
+	if conditionName != "" {
+		condition := cc.FindCondition(conditionName)
+		if condition == nil {
+			return nil, fmt.Errorf("condition %q not found (available: %s)", conditionName, strings.Join(cc.ConditionNames(), ", "))
+		}
+		highlighted = make(PinTable)
+		highlighted.Apply(condition.DesiredStates)
+	}
+
+	sources := make(map[PinKey][]SourceConfig)
+	if cc.Behavior != nil {
+		for _, source := range cc.Behavior.Sources {
+			key := NewPinKey(source.ClockID, source.BoardLabel)
+			sources[key] = append(sources[key], source)
+		}
+	}
+
+	model := &graphModel{}
+	nodeIDs := make(map[*PinInfo]string)
+	for si := range cc.Structure {
+		subsystem := &graphSubsystem{id: fmt.Sprintf("s%d", si), subsystem: &cc.Structure[si]}
+		model.subsystems = append(model.subsystems, subsystem)
+		for _, group := range pinGroups {
+			labels := make([]string, 0, len(group.pins(&cc.Structure[si].DPLL)))
+			for label := range group.pins(&cc.Structure[si].DPLL) {
+				labels = append(labels, label)
+			}
+			sort.Strings(labels)
+			for _, label := range labels {
+				pin, ok := pins.Lookup(cc.Structure[si].DPLL.ClockID, label)
+				if !ok || pin.SubsystemIndex != si {
+					// Subsystems without a clock ID, or sharing one with an earlier subsystem
+					config := group.pins(&cc.Structure[si].DPLL)[label]
+					pin = &PinInfo{SubsystemIndex: si, Subsystem: &cc.Structure[si], BoardLabel: label,
+						Group: group.name, Direction: group.direction, Kind: group.kind, Config: &config}
+				}
+				node := &graphPin{id: fmt.Sprintf("s%dp%d", si, len(subsystem.pins)), pin: pin}
+				node.sources = sources[pin.Key()]
+				if settings, set := highlighted[pin.Key()]; set {
+					node.highlight = conditionHighlight(pin, settings)
+				}
+				subsystem.pins = append(subsystem.pins, node)
+				nodeIDs[pin] = node.id
+			}
+		}
+	}
+
+	topology, _ := BuildTopology(cc, pins)
+	for _, link := range topology.Links {
+		edge := graphEdge{label: link.Name, inferred: link.Inferred()}
+		if link.Inferred() {
+			edge.label = link.From.Config.Connector
+		}
+		for _, end := range []struct {
+			pin       *PinInfo
+			external  string
+			id        *string
+			connector *bool
+		}{
+			{link.From, link.FromExternal, &edge.from, &edge.fromConnector},
+			{link.To, link.ToExternal, &edge.to, &edge.toConnector},
+		} {
+			if end.pin == nil {
+				*end.id = fmt.Sprintf("ext%d", len(model.externals))
+				model.externals = append(model.externals, [2]string{*end.id, end.external})
+				continue
+			}
+			*end.id = nodeIDs[end.pin]
+			*end.connector = end.pin.Config != nil && end.pin.Config.Connector != ""
+		}
+		if edge.from != "" && edge.to != "" {
+			model.edges = append(model.edges, edge)
+		}
+	}
+
+	return model, nil
+}
+
+// conditionHighlight classifies a pin by the DPLL states a condition sets on it: enabled if any of
+// them enables the pin, disabled if all of them disconnect it or set DisabledPriority
+func conditionHighlight(pin *PinInfo, settings PinSettings) pinHighlight {
+	highlight := highlightNone
+	for _, ps := range []PinState{settings.EEC, settings.PPS} {
+		if ps.Equal(PinState{}) {
+			continue
+		}
+		enabled := !inputDisabled(ps)
+		if pin.Direction == PinDirectionOutput {
+			enabled = ps.State != PinStateDisconnected
+		}
+		if enabled {
+			return highlightEnabled
+		}
+		highlight = highlightDisabled
+	}
+	return highlight
+}
+
+// lines returns the text lines describing a pin: board label, signal and sources
+func (p *graphPin) lines() []string {
+	lines := []string{p.pin.BoardLabel}
+	if config := p.pin.Config; config != nil {
+		if config.ESyncConfigName != "" {
+			lines = append(lines, "eSync "+config.ESyncConfigName)
+		} else if frequency, ok := p.pin.frequency(); ok {
+			lines = append(lines, formatFrequency(frequency))
+		}
+	}
+	for _, source := range p.sources {
+		lines = append(lines, fmt.Sprintf("source %s (%s)", source.Name, source.SourceType))
+	}
+	return lines
+}
+
+// connector returns the connector the pin is routed to, or an empty string
+func (p *graphPin) connector() string {
+	if p.pin.Config == nil {
+		return ""
+	}
+	return p.pin.Config.Connector
+}
+
+// clusterLabel returns the text lines describing a subsystem
+func (s *graphSubsystem) clusterLabel() []string {
+	lines := []string{s.subsystem.Name}
+	var details []string
+	if s.subsystem.HardwarePlugin != "" {
+		details = append(details, s.subsystem.HardwarePlugin)
+	}
+	if s.subsystem.DPLL.ClockID != 0 {
+		details = append(details, s.subsystem.DPLL.ClockID.String())
+	}
+	if len(details) > 0 {
+		lines = append(lines, strings.Join(details, ", "))
+	}
+	return lines
+}
+
+// dot renders the model as a Graphviz digraph. Pins are record nodes whose "conn" field is the
+// connector port that links attach to.
+func (m *graphModel) dot() string {
+	var sb strings.Builder
+	sb.WriteString("digraph clock_chain {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=record, fontname=\"Helvetica\", fontsize=10];\n")
+	sb.WriteString("  edge [fontname=\"Helvetica\", fontsize=9];\n")
+
+	for _, subsystem := range m.subsystems {
+		fmt.Fprintf(&sb, "\n  subgraph cluster_%s {\n", subsystem.id)
+		fmt.Fprintf(&sb, "    label=%s;\n", dotQuote(strings.Join(subsystem.clusterLabel(), "\n")))
+		for _, node := range subsystem.pins {
+			fields := []string{"<pin> " + dotRecordEscape(strings.Join(node.lines(), "\n"))}
+			if connector := node.connector(); connector != "" {
+				port := "<conn> " + dotRecordEscape(connector)
+				if node.pin.Direction == PinDirectionInput {
+					fields = append([]string{port}, fields...)
+				} else {
+					fields = append(fields, port)
+				}
+			}
+			attributes := []string{"label=" + dotQuote("{"+strings.Join(fields, "|")+"}")}
+			switch node.highlight {
+			case highlightEnabled:
+				attributes = append(attributes, "style=filled", "fillcolor=palegreen")
+			case highlightDisabled:
+				attributes = append(attributes, "style=\"filled,dashed\"", "fillcolor=lightcoral")
+			}
+			fmt.Fprintf(&sb, "    %s [%s];\n", node.id, strings.Join(attributes, ", "))
+		}
+		sb.WriteString("  }\n")
+	}
+
+	if len(m.externals) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, external := range m.externals {
+		fmt.Fprintf(&sb, "  %s [shape=ellipse, label=%s];\n", external[0], dotQuote(external[1]))
+	}
+
+	if len(m.edges) > 0 {
+		sb.WriteString("\n")
+	}
+	for _, edge := range m.edges {
+		from, to := edge.from, edge.to
+		if edge.fromConnector {
+			from += ":conn"
+		}
+		if edge.toConnector {
+			to += ":conn"
+		}
+		var attributes []string
+		if edge.label != "" {
+			attributes = append(attributes, "label="+dotQuote(edge.label))
+		}
+		if edge.inferred {
+			attributes = append(attributes, "style=dashed")
+		}
+		if len(attributes) > 0 {
+			fmt.Fprintf(&sb, "  %s -> %s [%s];\n", from, to, strings.Join(attributes, ", "))
+		} else {
+			fmt.Fprintf(&sb, "  %s -> %s;\n", from, to)
+		}
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotQuote quotes a DOT string, turning newlines into centered line breaks
+func dotQuote(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\"", "\\\"")
+	return "\"" + strings.ReplaceAll(s, "\n", "\\n") + "\""
+}
+
+// dotRecordEscape escapes the characters with a special meaning in record labels
+func dotRecordEscape(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("{}|<>", r) {
+			sb.WriteRune('\\')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// mermaid renders the model as a Mermaid flowchart. Mermaid has no ports, so connectors are shown
+// in the pin labels.
+func (m *graphModel) mermaid() string {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+
+	for _, subsystem := range m.subsystems {
+		fmt.Fprintf(&sb, "  subgraph %s[%s]\n", subsystem.id, mermaidQuote(subsystem.clusterLabel()))
+		for _, node := range subsystem.pins {
+			lines := node.lines()
+			if connector := node.connector(); connector != "" {
+				lines = append(lines, "["+connector+"]")
+			}
+			fmt.Fprintf(&sb, "    %s[%s]\n", node.id, mermaidQuote(lines))
+		}
+		sb.WriteString("  end\n")
+	}
+
+	for _, external := range m.externals {
+		fmt.Fprintf(&sb, "  %s([%s])\n", external[0], mermaidQuote([]string{external[1]}))
+	}
+
+	for _, edge := range m.edges {
+		arrow := "-->"
+		if edge.inferred {
+			arrow = "-.->"
+		}
+		if edge.label != "" {
+			fmt.Fprintf(&sb, "  %s %s|%s| %s\n", edge.from, arrow, mermaidQuote([]string{edge.label}), edge.to)
+		} else {
+			fmt.Fprintf(&sb, "  %s %s %s\n", edge.from, arrow, edge.to)
+		}
+	}
+
+	var enabled, disabled []string
+	for _, subsystem := range m.subsystems {
+		for _, node := range subsystem.pins {
+			switch node.highlight {
+			case highlightEnabled:
+				enabled = append(enabled, node.id)
+			case highlightDisabled:
+				disabled = append(disabled, node.id)
+			}
+		}
+	}
+	if len(enabled) > 0 {
+		sb.WriteString("  classDef enabled fill:#98fb98,stroke:#2e8b57\n")
+		fmt.Fprintf(&sb, "  class %s enabled\n", strings.Join(enabled, ","))
+	}
+	if len(disabled) > 0 {
+		sb.WriteString("  classDef disabled fill:#f08080,stroke:#b22222,stroke-dasharray:4\n")
+		fmt.Fprintf(&sb, "  class %s disabled\n", strings.Join(disabled, ","))
+	}
+
+	return sb.String()
+}
+
+// mermaidQuote joins label lines into a quoted Mermaid label
+func mermaidQuote(lines []string) string {
+	escaped := make([]string, len(lines))
+	for i, line := range lines {
+		escaped[i] = strings.ReplaceAll(line, "\"", "#quot;")
+	}
+	return "\"" + strings.Join(escaped, "<br/>") + "\""
+}
+
+// FindCondition returns the behavior condition with the given name, or nil if there is none
+func (cc *ClockChain) FindCondition(name string) *Condition {
+	if cc.Behavior == nil {
+		return nil
+	}
+	for i := range cc.Behavior.Conditions {
+		if cc.Behavior.Conditions[i].Name == name {
+			return &cc.Behavior.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// ConditionNames returns the names of the behavior conditions in the order they are listed
+func (cc *ClockChain) ConditionNames() []string {
+	if cc.Behavior == nil {
+		return nil
+	}
+	names := make([]string, len(cc.Behavior.Conditions))
+	for i, condition := range cc.Behavior.Conditions {
+		names[i] = fmt.Sprintf("%q", condition.Name)
+	}
+	return names
+}
+
+// runGraph implements the graph subcommand
+func runGraph(args []string) int {
+	flags := flag.NewFlagSet("graph", flag.ContinueOnError)
+	format := flags.String("format", string(GraphFormatDOT), "diagram format: dot or mermaid")
+	condition := flags.String("condition", "", "highlight the pins set by the desired states of this condition")
+	output := flags.String("o", "", "output file (default: stdout)")
+	report := addReportFlags(flags)
+	addLoadFlags(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser graph [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded := loadForCommand(flags.Arg(0), report)
+	if loaded == nil {
+		return 1
+	}
+
+	diagram, err := RenderGraph(loaded.Config, loaded.Plugins, GraphOptions{
+		Format:    GraphFormat(*format),
+		Condition: *condition,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := writeOutput(*output, diagram); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Lint rule IDs. Lint findings point at configuration that has no effect, which validation accepts.
+const (
+	RuleUnusedESync   = "unused-esync-definition"
+	RuleUnusedAlias   = "unused-clock-alias"
+	RuleUnusedRefSync = "unused-refsync-definition"
+	RuleUnusedSource  = "unused-source"
+	RuleDeadCondition = "dead-condition"
+)
+
+// LintRule is a check of the lint command. Rules are registered with RegisterLintRule.
+type LintRule struct {
+	// ID is the stable rule ID findings are reported and suppressed with
+	ID string
+
+	// Severity of the findings of the rule
+	Severity Severity
+
+	// Description summarizes what the rule reports
+	Description string
+
+	// Check walks a configuration and reports findings by path
+	Check func(ctx *LintContext, report LintReporter)
+}
+
+// LintReporter records a finding of a lint rule at a path of the configuration
+type LintReporter func(path, format string, args ...interface{})
+
+// LintContext is the configuration a lint rule checks
+type LintContext struct {
+	// Root is the YAML node tree of the configuration as written, before alias resolution
+	Root *yaml.Node
+
+	// Config is the merged configuration
+	Config *ClockChain
+
+	// Pins indexes the pins of the merged configuration, including plugin pins
+	Pins *PinIndex
+}
+
+// lintRules are the registered lint rules by ID
+var lintRules = make(map[string]LintRule)
+
+func init() {
+	for _, rule := range []LintRule{
+		{RuleUnusedESync, SeverityWarning, "eSync definitions that no pin references", lintUnusedESync},
+		{RuleUnusedAlias, SeverityWarning, "clock identifier aliases that no clock ID uses", lintUnusedAliases},
+		{RuleUnusedRefSync, SeverityWarning, "refSync definitions that no pin references", lintUnusedRefSync},
+		{RuleUnusedSource, SeverityWarning, "behavior sources that no condition mentions", lintUnusedSources},
+		{RuleDeadCondition, SeverityWarning, "conditions whose desired states all target pins that do not exist", lintDeadConditions},
+	} {
+		RegisterLintRule(rule)
+	}
+}
+
+// RegisterLintRule adds a rule to the lint command. It panics if a rule with the same ID is registered.
+func RegisterLintRule(rule LintRule) {
+	if _, exists := lintRules[rule.ID]; exists {
+		panic(fmt.Sprintf("lint rule %s registered twice", rule.ID))
+	}
+	lintRules[rule.ID] = rule
+}
+
+// LintRules returns the registered lint rules, sorted by ID
+func LintRules() []LintRule {
+	rules := make([]LintRule, 0, len(lintRules))
+	for _, rule := range lintRules {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// Lint runs every registered rule against a configuration and returns the findings
+func Lint(ctx *LintContext) ValidationErrors {
+	var findings ValidationErrors
+	for _, rule := range LintRules() {
+		rule.Check(ctx, func(path, format string, args ...interface{}) {
+			findings = append(findings, ValidationError{
+				Severity: rule.Severity,
+				Rule:     rule.ID,
+				Path:     path,
+				Message:  fmt.Sprintf(format, args...),
+			})
+		})
+	}
+	return findings
+}
+
+// lintUnusedESync reports eSync definitions that no pin references by esyncConfigName
+func lintUnusedESync(ctx *LintContext, report LintReporter) {
+	if ctx.Config.CommonDefinitions == nil {
+		return
+	}
+	used := make(map[string]bool)
+	for _, subsystem := range ctx.Config.Structure {
+		for _, group := range pinGroups {
+			for _, config := range group.pins(&subsystem.DPLL) {
+				used[config.ESyncConfigName] = true
+			}
+		}
+	}
+	for i, definition := range ctx.Config.CommonDefinitions.ESyncDefinitions {
+		if definition.Name != "" && !used[definition.Name] {
+			report(pathIndex("commonDefinitions.eSyncDefinitions", i), "eSync definition %s is not referenced by any pin", definition.Name)
+		}
+	}
+}
+
+// lintUnusedAliases reports clock identifier aliases that no clock ID of the source document uses
+func lintUnusedAliases(ctx *LintContext, report LintReporter) {
+	if ctx.Config.CommonDefinitions == nil || ctx.Root == nil {
+		return
+	}
+	used := make(map[string]bool)
+	walkClockIDs(ctx.Root, "", func(node *yaml.Node, path string) {
+		if !strings.HasPrefix(path, "commonDefinitions.") {
+			used[node.Value] = true
+		}
+	})
+	for i, identifier := range ctx.Config.CommonDefinitions.ClockIdentifiers {
+		if identifier.Alias != "" && !used[identifier.Alias] {
+			report(pathIndex("commonDefinitions.clockIdentifiers", i), "clock alias %s is not used by any clock ID", identifier.Alias)
+		}
+	}
+}
+
+// lintUnusedRefSync reports refSync definitions that no pin references by refSyncConfigName
+func lintUnusedRefSync(ctx *LintContext, report LintReporter) {
+	if ctx.Config.CommonDefinitions == nil {
+		return
+	}
+	used := make(map[string]bool)
+	for _, subsystem := range ctx.Config.Structure {
+		for _, group := range pinGroups {
+			for _, config := range group.pins(&subsystem.DPLL) {
+				used[config.RefSyncConfigName] = true
+			}
+		}
+	}
+	for i, definition := range ctx.Config.CommonDefinitions.RefSyncDefinitions {
+		if definition.Name != "" && !used[definition.Name] {
+			report(pathIndex("commonDefinitions.refSyncDefinitions", i), "refSync definition %s is not referenced by any pin", definition.Name)
+		}
+	}
+}
+
+// lintUnusedSources reports behavior sources that no condition mentions
+func lintUnusedSources(ctx *LintContext, report LintReporter) {
+	if ctx.Config.Behavior == nil {
+		return
+	}
+	mentioned := make(map[string]bool)
+	for _, condition := range ctx.Config.Behavior.Conditions {
+		for i := range condition.Sources {
+			for _, state := range condition.Sources[i].leaves() {
+				mentioned[state.SourceName] = true
+			}
+		}
+	}
+	for i, source := range ctx.Config.Behavior.Sources {
+		if !mentioned[source.Name] {
+			report(pathIndex("behavior.sources", i), "source %s is not mentioned by any condition", source.Name)
+		}
+	}
+}
+
+// lintDeadConditions reports conditions with desired states that all target pins that do not exist,
+// so that the condition has no effect. The missing pins themselves are validation errors.
+func lintDeadConditions(ctx *LintContext, report LintReporter) {
+	if ctx.Config.Behavior == nil {
+		return
+	}
+	for i, condition := range ctx.Config.Behavior.Conditions {
+		if len(condition.DesiredStates) == 0 {
+			continue
+		}
+		dead := true
+		for _, ds := range condition.DesiredStates {
+			if _, ok := ctx.Pins.Lookup(ds.ClockID, ds.BoardLabel); ok {
+				dead = false
+				break
+			}
+		}
+		if dead {
+			report(ctx.Config.conditionPath(i), "condition %s has no effect: none of its desired states targets an existing pin", condition.Name)
+		}
+	}
+}
+
+// Lint runs the lint rules against a loaded configuration and adds their findings to the
+// findings and suppressed findings of the configuration
+func (lc *LoadedConfig) Lint() {
+	findings := Lint(&LintContext{Root: lc.Root, Config: lc.Config, Pins: NewPinIndex(lc.Config, lc.Plugins)})
+	findings.Locate(lc.Root)
+	kept, suppressed := findings.Suppress(lc.Root, lc.Config.Lint)
+	lc.Findings = append(lc.Findings, kept...)
+	lc.Suppressed = append(lc.Suppressed, suppressed...)
+	lc.Findings.Sort()
+}
+
+// runLint implements the lint subcommand. It reports validation and lint findings together and
+// exits with 1 if any of them is at least as serious as --fail-on, warning by default.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	listRules := flags.Bool("list-rules", false, "list the lint rules and exit")
+	report := (&ReportOptions{FailOn: SeverityWarning, MinSeverity: SeverityInfo}).register(flags)
+	addLoadFlags(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser lint [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if *listRules {
+		printLintRules(os.Stdout)
+		return 0
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded, err := LoadConfig(flags.Arg(0), io.Discard)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	loaded.Lint()
+	if loaded.reportFindings(os.Stdout, report) {
+		return 1
+	}
+	fmt.Printf("%s: no findings at or above %s\n", loaded.Path, report.FailOn)
+	return 0
+}
+
+// printLintRules prints the registered lint rules as a table
+func printLintRules(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RULE\tSEVERITY\tDESCRIPTION")
+	for _, rule := range LintRules() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", rule.ID, rule.Severity, rule.Description)
+	}
+	tw.Flush()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -11,12 +12,18 @@ import (
 // Version can be set during build time
 var Version = "dev"
 
+// SchemaFile overrides the OpenAPI specification every configuration document is checked against.
+// If empty, the ptp-hw.yaml built into the binary is used.
+var SchemaFile = ""
+
 func main() {
 	if len(os.Args) < 2 {
 		fmt.Println("PTP Hardware Configuration Parser")
 		fmt.Printf("Version: %s\n", Version)
-		fmt.Println("Usage: go run . <config-file>")
+		fmt.Println("Usage: go run . [--fail-on severity] [--min-severity severity] [--schema file] <config-file>")
+		fmt.Println("       go run . <command> [flags] <config-file>")
 		fmt.Println("       go run . --version")
+		fmt.Printf("Commands: %s\n", strings.Join(subcommandNames(), ", "))
 		os.Exit(1)
 	}
 
@@ -26,49 +33,34 @@ func main() {
 		os.Exit(0)
 	}
 
-	configFile := os.Args[1]
-
-	// Read file
-	data, err := os.ReadFile(configFile)
-	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-		os.Exit(1)
+	if command, ok := subcommands[os.Args[1]]; ok {
+		os.Exit(command(os.Args[2:]))
 	}
 
-	// Parse YAML
-	var config ClockChain
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		fmt.Printf("Error parsing YAML: %v\n", err)
+	flags := flag.NewFlagSet("ptp-config-parser", flag.ExitOnError)
+	report := addReportFlags(flags)
+	addLoadFlags(flags, false)
+	flags.Parse(os.Args[1:])
+	if flags.NArg() != 1 {
+		fmt.Println("Usage: go run . [--fail-on severity] [--min-severity severity] [--schema file] <config-file>")
 		os.Exit(1)
 	}
+	configFile := flags.Arg(0)
 
-	// Resolve clock aliases early
-	if err := config.ResolveClockAliases(); err != nil {
-		fmt.Printf("Error resolving clock aliases: %v\n", err)
+	loaded, err := LoadConfig(configFile, os.Stdout)
+	if err != nil {
+		fmt.Printf("Error %v\n", err)
 		os.Exit(1)
 	}
+	config := loaded.Config
 
-	// Load hardware plugins and apply defaults
-	pluginManager, err := NewPluginManager("plugins")
-	if err != nil {
-		fmt.Printf("Warning: Failed to load plugins: %v\n", err)
-		fmt.Println("Continuing without plugin defaults...")
-	} else {
-		fmt.Printf("Loaded %d hardware plugins: %v\n", len(pluginManager.ListPlugins()), pluginManager.ListPlugins())
-
-		// Apply plugin defaults to user configuration
-		if err := pluginManager.MergeUserConfigWithDefaults(&config); err != nil {
-			fmt.Printf("Error applying plugin defaults: %v\n", err)
-			os.Exit(1)
-		}
-		fmt.Println("Successfully applied hardware plugin defaults")
-
+	if loaded.Plugins != nil {
 		// Output the merged configuration
 		fmt.Println("\n" + strings.Repeat("=", 60))
 		fmt.Println("MERGED CONFIGURATION (User Config + Plugin Defaults)")
 		fmt.Println(strings.Repeat("=", 60))
 
-		mergedYAML, err := yaml.Marshal(&config)
+		mergedYAML, err := yaml.Marshal(config)
 		if err != nil {
 			fmt.Printf("Warning: Failed to marshal merged config: %v\n", err)
 		} else {
@@ -77,9 +69,7 @@ func main() {
 		fmt.Println(strings.Repeat("=", 60))
 	}
 
-	// Validate
-	if err := config.Validate(); err != nil {
-		fmt.Printf("Validation error: %v\n", err)
+	if loaded.reportFindings(os.Stdout, report) {
 		os.Exit(1)
 	}
 
@@ -92,3 +82,34 @@ func main() {
 		fmt.Printf("  %d. %s\n", i+1, subsystem.String())
 	}
 }
+
+// ParseClockChain decodes a configuration document and returns it together with its YAML node tree,
+// which keeps the source positions needed for schema validation and error reporting. Clock aliases
+// are resolved while decoding; the node tree keeps them as written. Unknown keys are rejected, except
+// for extension keys starting with ExtensionKeyPrefix.
+func ParseClockChain(data []byte) (*ClockChain, *yaml.Node, error) {
+	config, root, _, err := parseClockChain(data)
+	return config, root, err
+}
+
+// parseClockChain implements ParseClockChain and also returns the node tree with the clock aliases
+// resolved, which is the tree the schema applies to
+func parseClockChain(data []byte) (*ClockChain, *yaml.Node, *yaml.Node, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, nil, nil, err
+	}
+	if root.Kind == 0 {
+		return nil, nil, nil, fmt.Errorf("document is empty")
+	}
+
+	resolved := cloneNode(&root, make(map[*yaml.Node]*yaml.Node))
+	if err := resolveClockAliases(resolved); err != nil {
+		return nil, nil, nil, err
+	}
+	var config ClockChain
+	if err := decodeStrict(resolved, &config); err != nil {
+		return nil, nil, nil, err
+	}
+	return &config, &root, resolved, nil
+}
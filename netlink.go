@@ -0,0 +1,367 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Linux DPLL generic netlink family, see include/uapi/linux/dpll.h
+const (
+	dpllFamilyName    = "dpll"
+	dpllFamilyVersion = 1
+
+	dpllCmdDeviceGet = 2
+	dpllCmdPinGet    = 8
+	dpllCmdPinSet    = 9
+
+	dpllAttrDeviceID         = 1
+	dpllAttrDeviceModuleName = 2
+	dpllAttrDeviceClockID    = 4
+	dpllAttrDeviceLockStatus = 7
+	dpllAttrDeviceType       = 9
+
+	dpllAttrPinID             = 1
+	dpllAttrPinParentID       = 2
+	dpllAttrPinModuleName     = 3
+	dpllAttrPinClockID        = 5
+	dpllAttrPinBoardLabel     = 6
+	dpllAttrPinDirection      = 10
+	dpllAttrPinFrequency      = 11
+	dpllAttrPinPrio           = 15
+	dpllAttrPinState          = 16
+	dpllAttrPinParentDevice   = 18
+	dpllAttrPinPhaseAdjust    = 22
+	dpllAttrPinESyncFrequency = 25
+
+	dpllDeviceTypePPS = 1
+	dpllDeviceTypeEEC = 2
+
+	dpllPinDirectionInput  = 1
+	dpllPinDirectionOutput = 2
+
+	dpllPinStateConnected    = 1
+	dpllPinStateDisconnected = 2
+	dpllPinStateSelectable   = 3
+
+	dpllLockStatusUnlocked       = 1
+	dpllLockStatusLocked         = 2
+	dpllLockStatusLockedHoldover = 3
+	dpllLockStatusHoldover       = 4
+)
+
+// Netlink and generic netlink controller protocol, see include/uapi/linux/netlink.h and genetlink.h
+const (
+	nlmsgHeaderLen = 16
+	genlHeaderLen  = 4
+	nlaHeaderLen   = 4
+	nlaAlignTo     = 4
+	nlaFlagNested  = 0x8000
+	nlaTypeMask    = 0x3fff
+
+	nlmsgTypeError = 2
+	nlmsgTypeDone  = 3
+
+	nlmFlagRequest = 0x1
+	nlmFlagMulti   = 0x2
+	nlmFlagAck     = 0x4
+	nlmFlagDump    = 0x300
+
+	genlCtrlFamilyID       = 0x10
+	genlCtrlFamilyVersion  = 2
+	genlCtrlCmdGetFamily   = 3
+	genlCtrlAttrFamilyID   = 1
+	genlCtrlAttrFamilyName = 2
+)
+
+// Routing netlink, see include/uapi/linux/rtnetlink.h and if_link.h
+const (
+	ifinfomsgLen = 16
+
+	iflaIfname  = 3
+	iflaDPLLPin = 65
+)
+
+var dpllPinStates = map[string]uint32{
+	"connected":    dpllPinStateConnected,
+	"disconnected": dpllPinStateDisconnected,
+	"selectable":   dpllPinStateSelectable,
+}
+
+var dpllLockStatuses = map[uint32]string{
+	dpllLockStatusUnlocked:       "unlocked",
+	dpllLockStatusLocked:         "locked",
+	dpllLockStatusLockedHoldover: "locked-ho-acq",
+	dpllLockStatusHoldover:       "holdover",
+}
+
+// netlinkAttr is a decoded netlink attribute
+type netlinkAttr struct {
+	Type uint16
+	Data []byte
+}
+
+func nlaAlign(length int) int {
+	return (length + nlaAlignTo - 1) &^ (nlaAlignTo - 1)
+}
+
+// netlinkEncoder builds a sequence of netlink attributes
+type netlinkEncoder struct {
+	buf []byte
+}
+
+func (e *netlinkEncoder) bytes(attrType uint16, data []byte) {
+	header := make([]byte, nlaHeaderLen)
+	binary.NativeEndian.PutUint16(header[0:2], uint16(nlaHeaderLen+len(data)))
+	binary.NativeEndian.PutUint16(header[2:4], attrType)
+	e.buf = append(e.buf, header...)
+	e.buf = append(e.buf, data...)
+	e.buf = append(e.buf, make([]byte, nlaAlign(len(data))-len(data))...)
+}
+
+func (e *netlinkEncoder) uint16(attrType uint16, value uint16) {
+	data := make([]byte, 2)
+	binary.NativeEndian.PutUint16(data, value)
+	e.bytes(attrType, data)
+}
+
+func (e *netlinkEncoder) uint32(attrType uint16, value uint32) {
+	data := make([]byte, 4)
+	binary.NativeEndian.PutUint32(data, value)
+	e.bytes(attrType, data)
+}
+
+func (e *netlinkEncoder) uint64(attrType uint16, value uint64) {
+	data := make([]byte, 8)
+	binary.NativeEndian.PutUint64(data, value)
+	e.bytes(attrType, data)
+}
+
+func (e *netlinkEncoder) string(attrType uint16, value string) {
+	e.bytes(attrType, append([]byte(value), 0))
+}
+
+func (e *netlinkEncoder) nested(attrType uint16, encode func(*netlinkEncoder)) {
+	var inner netlinkEncoder
+	encode(&inner)
+	e.bytes(attrType|nlaFlagNested, inner.buf)
+}
+
+// decodeNetlinkAttrs splits a buffer into netlink attributes
+func decodeNetlinkAttrs(buf []byte) ([]netlinkAttr, error) {
+	var attrs []netlinkAttr
+	for len(buf) >= nlaHeaderLen {
+		length := int(binary.NativeEndian.Uint16(buf[0:2]))
+		if length < nlaHeaderLen || length > len(buf) {
+			return nil, fmt.Errorf("malformed netlink attribute of length %d", length)
+		}
+		attrs = append(attrs, netlinkAttr{
+			Type: binary.NativeEndian.Uint16(buf[2:4]) & nlaTypeMask,
+			Data: buf[nlaHeaderLen:length],
+		})
+		if aligned := nlaAlign(length); aligned < len(buf) {
+			buf = buf[aligned:]
+		} else {
+			buf = nil
+		}
+	}
+	return attrs, nil
+}
+
+func (a netlinkAttr) uint16() uint16 {
+	if len(a.Data) < 2 {
+		return 0
+	}
+	return binary.NativeEndian.Uint16(a.Data)
+}
+
+func (a netlinkAttr) uint32() uint32 {
+	if len(a.Data) < 4 {
+		return 0
+	}
+	return binary.NativeEndian.Uint32(a.Data)
+}
+
+func (a netlinkAttr) uint64() uint64 {
+	if len(a.Data) < 8 {
+		return uint64(a.uint32())
+	}
+	return binary.NativeEndian.Uint64(a.Data)
+}
+
+func (a netlinkAttr) string() string {
+	data := a.Data
+	for len(data) > 0 && data[len(data)-1] == 0 {
+		data = data[:len(data)-1]
+	}
+	return string(data)
+}
+
+// genlMessage prefixes attributes with a generic netlink header
+func genlMessage(cmd, version uint8, attrs []byte) []byte {
+	return append([]byte{cmd, version, 0, 0}, attrs...)
+}
+
+// encodeDPLLPinSet encodes the attributes of a DPLL_CMD_PIN_SET request
+func encodeDPLLPinSet(request DPLLPinSet) ([]byte, error) {
+	var e netlinkEncoder
+	e.uint32(dpllAttrPinID, request.PinID)
+	if request.Frequency != nil {
+		e.uint64(dpllAttrPinFrequency, *request.Frequency)
+	}
+	if request.PhaseAdjust != nil {
+		e.uint32(dpllAttrPinPhaseAdjust, uint32(*request.PhaseAdjust))
+	}
+	if request.ESyncFrequency != nil {
+		e.uint64(dpllAttrPinESyncFrequency, *request.ESyncFrequency)
+	}
+
+	if request.Priority != nil || request.State != "" {
+		if request.ParentDevice == nil {
+			return nil, fmt.Errorf("pin %d: priority and state require a parent device", request.PinID)
+		}
+		var state uint32
+		if request.State != "" {
+			var ok bool
+			if state, ok = dpllPinStates[request.State]; !ok {
+				return nil, fmt.Errorf("pin %d: invalid state %q", request.PinID, request.State)
+			}
+		}
+		e.nested(dpllAttrPinParentDevice, func(parent *netlinkEncoder) {
+			parent.uint32(dpllAttrPinParentID, *request.ParentDevice)
+			if request.Priority != nil {
+				parent.uint32(dpllAttrPinPrio, *request.Priority)
+			}
+			if request.State != "" {
+				parent.uint32(dpllAttrPinState, state)
+			}
+		})
+	}
+	return e.buf, nil
+}
+
+// encodeGetLink encodes a RTM_GETLINK request for a network interface
+func encodeGetLink(iface string) []byte {
+	var attrs netlinkEncoder
+	attrs.string(iflaIfname, iface)
+	return append(make([]byte, ifinfomsgLen), attrs.buf...)
+}
+
+// decodeLinkDPLLPin returns the ID of the DPLL pin in a RTM_NEWLINK reply, if the interface has one
+func decodeLinkDPLLPin(buf []byte) (uint32, bool, error) {
+	if len(buf) < ifinfomsgLen {
+		return 0, false, fmt.Errorf("truncated link message")
+	}
+	attrs, err := decodeNetlinkAttrs(buf[ifinfomsgLen:])
+	if err != nil {
+		return 0, false, err
+	}
+	for _, attr := range attrs {
+		if attr.Type != iflaDPLLPin {
+			continue
+		}
+		pinAttrs, err := decodeNetlinkAttrs(attr.Data)
+		if err != nil {
+			return 0, false, err
+		}
+		for _, pinAttr := range pinAttrs {
+			if pinAttr.Type == dpllAttrPinID {
+				return pinAttr.uint32(), true, nil
+			}
+		}
+	}
+	return 0, false, nil
+}
+
+// decodeDPLLDevice decodes the attributes of a DPLL_CMD_DEVICE_GET reply
+func decodeDPLLDevice(buf []byte) (DPLLDevice, error) {
+	attrs, err := decodeNetlinkAttrs(buf)
+	if err != nil {
+		return DPLLDevice{}, err
+	}
+	var device DPLLDevice
+	for _, attr := range attrs {
+		switch attr.Type {
+		case dpllAttrDeviceID:
+			device.ID = attr.uint32()
+		case dpllAttrDeviceModuleName:
+			device.ModuleName = attr.string()
+		case dpllAttrDeviceClockID:
+			device.ClockID = ClockID(attr.uint64())
+		case dpllAttrDeviceLockStatus:
+			device.LockStatus = dpllLockStatuses[attr.uint32()]
+		case dpllAttrDeviceType:
+			switch attr.uint32() {
+			case dpllDeviceTypePPS:
+				device.Type = DPLLTypePPS
+			case dpllDeviceTypeEEC:
+				device.Type = DPLLTypeEEC
+			}
+		}
+	}
+	return device, nil
+}
+
+// decodeDPLLPin decodes the attributes of a DPLL_CMD_PIN_GET reply
+func decodeDPLLPin(buf []byte) (DPLLPin, error) {
+	attrs, err := decodeNetlinkAttrs(buf)
+	if err != nil {
+		return DPLLPin{}, err
+	}
+	var pin DPLLPin
+	for _, attr := range attrs {
+		switch attr.Type {
+		case dpllAttrPinID:
+			pin.ID = attr.uint32()
+		case dpllAttrPinModuleName:
+			pin.ModuleName = attr.string()
+		case dpllAttrPinClockID:
+			pin.ClockID = ClockID(attr.uint64())
+		case dpllAttrPinBoardLabel:
+			pin.BoardLabel = attr.string()
+		case dpllAttrPinFrequency:
+			pin.Frequency = attr.uint64()
+		case dpllAttrPinPhaseAdjust:
+			pin.PhaseAdjust = int32(attr.uint32())
+		case dpllAttrPinESyncFrequency:
+			pin.ESyncFrequency = attr.uint64()
+		case dpllAttrPinParentDevice:
+			parent, err := decodeDPLLPinParent(attr.Data)
+			if err != nil {
+				return DPLLPin{}, err
+			}
+			pin.Parents = append(pin.Parents, parent)
+		}
+	}
+	return pin, nil
+}
+
+func decodeDPLLPinParent(buf []byte) (DPLLPinParent, error) {
+	attrs, err := decodeNetlinkAttrs(buf)
+	if err != nil {
+		return DPLLPinParent{}, err
+	}
+	var parent DPLLPinParent
+	for _, attr := range attrs {
+		switch attr.Type {
+		case dpllAttrPinParentID:
+			parent.DeviceID = attr.uint32()
+		case dpllAttrPinDirection:
+			switch attr.uint32() {
+			case dpllPinDirectionInput:
+				parent.Direction = PinDirectionInput
+			case dpllPinDirectionOutput:
+				parent.Direction = PinDirectionOutput
+			}
+		case dpllAttrPinPrio:
+			priority := attr.uint32()
+			parent.Priority = &priority
+		case dpllAttrPinState:
+			for name, value := range dpllPinStates {
+				if value == attr.uint32() {
+					parent.State = name
+				}
+			}
+		}
+	}
+	return parent, nil
+}
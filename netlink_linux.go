@@ -0,0 +1,207 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// NetlinkDPLL is a DPLLBackend using the Linux DPLL generic netlink family
+type NetlinkDPLL struct {
+	fd       int
+	familyID uint16
+	seq      uint32
+}
+
+// NewNetlinkDPLL opens a generic netlink socket and resolves the DPLL family
+func NewNetlinkDPLL() (*NetlinkDPLL, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_GENERIC)
+	if err != nil {
+		return nil, fmt.Errorf("opening generic netlink socket: %w", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("binding generic netlink socket: %w", err)
+	}
+
+	n := &NetlinkDPLL{fd: fd}
+	var attrs netlinkEncoder
+	attrs.string(genlCtrlAttrFamilyName, dpllFamilyName)
+	replies, err := n.request(genlCtrlFamilyID, genlMessage(genlCtrlCmdGetFamily, genlCtrlFamilyVersion, attrs.buf), 0)
+	if err != nil {
+		n.Close()
+		return nil, fmt.Errorf("resolving netlink family %s (is the dpll module loaded?): %w", dpllFamilyName, err)
+	}
+	for _, reply := range replies {
+		decoded, err := decodeNetlinkAttrs(reply)
+		if err != nil {
+			n.Close()
+			return nil, err
+		}
+		for _, attr := range decoded {
+			if attr.Type == genlCtrlAttrFamilyID {
+				n.familyID = attr.uint16()
+			}
+		}
+	}
+	if n.familyID == 0 {
+		n.Close()
+		return nil, fmt.Errorf("netlink family %s not found", dpllFamilyName)
+	}
+	return n, nil
+}
+
+// Devices implements DPLLBackend
+func (n *NetlinkDPLL) Devices() ([]DPLLDevice, error) {
+	replies, err := n.request(n.familyID, genlMessage(dpllCmdDeviceGet, dpllFamilyVersion, nil), nlmFlagDump)
+	if err != nil {
+		return nil, err
+	}
+	devices := make([]DPLLDevice, 0, len(replies))
+	for _, reply := range replies {
+		device, err := decodeDPLLDevice(reply)
+		if err != nil {
+			return nil, err
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// Pins implements DPLLBackend
+func (n *NetlinkDPLL) Pins() ([]DPLLPin, error) {
+	replies, err := n.request(n.familyID, genlMessage(dpllCmdPinGet, dpllFamilyVersion, nil), nlmFlagDump)
+	if err != nil {
+		return nil, err
+	}
+	pins := make([]DPLLPin, 0, len(replies))
+	for _, reply := range replies {
+		pin, err := decodeDPLLPin(reply)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+	return pins, nil
+}
+
+// SetPin implements DPLLBackend
+func (n *NetlinkDPLL) SetPin(request DPLLPinSet) error {
+	attrs, err := encodeDPLLPinSet(request)
+	if err != nil {
+		return err
+	}
+	_, err = n.request(n.familyID, genlMessage(dpllCmdPinSet, dpllFamilyVersion, attrs), nlmFlagAck)
+	return err
+}
+
+// InterfacePinID returns the ID of the DPLL pin of a network interface, as reported by routing netlink
+func (n *NetlinkDPLL) InterfacePinID(iface string) (uint32, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return 0, fmt.Errorf("opening routing netlink socket: %w", err)
+	}
+	defer syscall.Close(fd)
+
+	payload := encodeGetLink(iface)
+	msg := make([]byte, nlmsgHeaderLen, nlmsgHeaderLen+len(payload))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(nlmsgHeaderLen+len(payload)))
+	binary.NativeEndian.PutUint16(msg[4:6], syscall.RTM_GETLINK)
+	binary.NativeEndian.PutUint16(msg[6:8], nlmFlagRequest)
+	binary.NativeEndian.PutUint32(msg[8:12], 1)
+	msg = append(msg, payload...)
+	if err := syscall.Sendto(fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return 0, fmt.Errorf("sending netlink message: %w", err)
+	}
+
+	buf := make([]byte, os.Getpagesize()*8)
+	read, _, err := syscall.Recvfrom(fd, buf, 0)
+	if err != nil {
+		return 0, fmt.Errorf("receiving netlink message: %w", err)
+	}
+	messages, err := syscall.ParseNetlinkMessage(buf[:read])
+	if err != nil {
+		return 0, fmt.Errorf("parsing netlink message: %w", err)
+	}
+	for _, m := range messages {
+		switch m.Header.Type {
+		case nlmsgTypeError:
+			if len(m.Data) >= 4 {
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return 0, fmt.Errorf("interface %s: %w", iface, syscall.Errno(-errno))
+				}
+			}
+		case syscall.RTM_NEWLINK:
+			pinID, ok, err := decodeLinkDPLLPin(m.Data)
+			if err != nil {
+				return 0, err
+			}
+			if ok {
+				return pinID, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("interface %s has no DPLL pin", iface)
+}
+
+// Close implements DPLLBackend
+func (n *NetlinkDPLL) Close() error {
+	return syscall.Close(n.fd)
+}
+
+// request sends a generic netlink message and returns the attributes of every reply, after the
+// generic netlink header. It waits for the end of a dump or for the acknowledgement of a request.
+func (n *NetlinkDPLL) request(msgType uint16, payload []byte, flags uint16) ([][]byte, error) {
+	n.seq++
+	msg := make([]byte, nlmsgHeaderLen, nlmsgHeaderLen+len(payload))
+	binary.NativeEndian.PutUint32(msg[0:4], uint32(nlmsgHeaderLen+len(payload)))
+	binary.NativeEndian.PutUint16(msg[4:6], msgType)
+	binary.NativeEndian.PutUint16(msg[6:8], nlmFlagRequest|flags)
+	binary.NativeEndian.PutUint32(msg[8:12], n.seq)
+	msg = append(msg, payload...)
+	if err := syscall.Sendto(n.fd, msg, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, fmt.Errorf("sending netlink message: %w", err)
+	}
+
+	var replies [][]byte
+	buf := make([]byte, os.Getpagesize()*8)
+	for {
+		read, _, err := syscall.Recvfrom(n.fd, buf, 0)
+		if err != nil {
+			return nil, fmt.Errorf("receiving netlink message: %w", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buf[:read])
+		if err != nil {
+			return nil, fmt.Errorf("parsing netlink message: %w", err)
+		}
+		for _, m := range messages {
+			if m.Header.Seq != n.seq {
+				continue
+			}
+			switch m.Header.Type {
+			case nlmsgTypeDone:
+				return replies, nil
+			case nlmsgTypeError:
+				if len(m.Data) < 4 {
+					return nil, fmt.Errorf("truncated netlink error message")
+				}
+				if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+					return nil, syscall.Errno(-errno)
+				}
+				// Acknowledgement
+				return replies, nil
+			default:
+				if len(m.Data) < genlHeaderLen {
+					return nil, fmt.Errorf("truncated generic netlink message")
+				}
+				replies = append(replies, m.Data[genlHeaderLen:])
+				if m.Header.Flags&nlmFlagMulti == 0 && flags&nlmFlagAck == 0 {
+					return replies, nil
+				}
+			}
+		}
+	}
+}
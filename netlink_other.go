@@ -0,0 +1,38 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// NetlinkDPLL is a DPLLBackend using the Linux DPLL generic netlink family. It is only available on Linux.
+type NetlinkDPLL struct{}
+
+// NewNetlinkDPLL always fails on platforms other than Linux
+func NewNetlinkDPLL() (*NetlinkDPLL, error) {
+	return nil, fmt.Errorf("the DPLL netlink backend is only supported on Linux")
+}
+
+// Devices implements DPLLBackend
+func (n *NetlinkDPLL) Devices() ([]DPLLDevice, error) {
+	return nil, fmt.Errorf("the DPLL netlink backend is only supported on Linux")
+}
+
+// Pins implements DPLLBackend
+func (n *NetlinkDPLL) Pins() ([]DPLLPin, error) {
+	return nil, fmt.Errorf("the DPLL netlink backend is only supported on Linux")
+}
+
+// SetPin implements DPLLBackend
+func (n *NetlinkDPLL) SetPin(request DPLLPinSet) error {
+	return fmt.Errorf("the DPLL netlink backend is only supported on Linux")
+}
+
+// InterfacePinID always fails on platforms other than Linux
+func (n *NetlinkDPLL) InterfacePinID(iface string) (uint32, error) {
+	return 0, fmt.Errorf("the DPLL netlink backend is only supported on Linux")
+}
+
+// Close implements DPLLBackend
+func (n *NetlinkDPLL) Close() error {
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import "sort"
+
+// PinDirection is the signal direction of a DPLL pin
+type PinDirection string
+
+const (
+	// PinDirectionUnknown is used for pins known only by board label, e.g. from plugin defaults without capabilities
+	PinDirectionUnknown PinDirection = ""
+	PinDirectionInput   PinDirection = "input"
+	PinDirectionOutput  PinDirection = "output"
+)
+
+// PinKind distinguishes phase pins from frequency pins
+type PinKind string
+
+const (
+	PinKindUnknown   PinKind = ""
+	PinKindPhase     PinKind = "phase"
+	PinKindFrequency PinKind = "frequency"
+)
+
+// PinInfo describes a DPLL pin resolved by clock ID and board label
+type PinInfo struct {
+	// SubsystemIndex is the index of the owning subsystem in ClockChain.Structure
+	SubsystemIndex int
+
+	// Subsystem is the owning subsystem
+	Subsystem *Subsystem
+
+	// BoardLabel is the pin board label
+	BoardLabel string
+
+	// Group is the DPLL pin map declaring the pin ("phaseInputs", "phaseOutputs", "frequencyInputs"
+	// or "frequencyOutputs"), or empty if the pin is only known to the hardware plugin
+	Group string
+
+	// Direction and Kind are derived from the declaring pin map, or from the plugin pin
+	// capabilities for pins only known to the hardware plugin
+	Direction PinDirection
+	Kind      PinKind
+
+	// Config is the user pin configuration, or nil if the pin is only known to the hardware plugin
+	Config *PinConfig
+}
+
+// Declared reports whether the pin is declared in the subsystem DPLL pin maps
+func (p *PinInfo) Declared() bool {
+	return p.Config != nil
+}
+
+// PinIndex resolves (clock ID, board label) pairs to the pins of a clock chain
+type PinIndex struct {
+	subsystems map[ClockID]*Subsystem
+	pins       map[ClockID]map[string]*PinInfo
+}
+
+// pinGroups lists the DPLL pin maps together with the direction and kind of the pins they hold
+var pinGroups = []struct {
+	name      string
+	direction PinDirection
+	kind      PinKind
+	pins      func(d *DPLL) map[string]PinConfig
+}{
+	{"phaseInputs", PinDirectionInput, PinKindPhase, func(d *DPLL) map[string]PinConfig { return d.PhaseInputs }},
+	{"phaseOutputs", PinDirectionOutput, PinKindPhase, func(d *DPLL) map[string]PinConfig { return d.PhaseOutputs }},
+	{"frequencyInputs", PinDirectionInput, PinKindFrequency, func(d *DPLL) map[string]PinConfig { return d.FrequencyInputs }},
+	{"frequencyOutputs", PinDirectionOutput, PinKindFrequency, func(d *DPLL) map[string]PinConfig { return d.FrequencyOutputs }},
+}
+
+// NewPinIndex indexes the pins declared in the DPLL pin maps of every subsystem with a clock ID,
+// plus the pins known to each subsystem's hardware plugin. The plugin manager may be nil.
+func NewPinIndex(cc *ClockChain, pm *PluginManager) *PinIndex {
+	pi := &PinIndex{
+		subsystems: make(map[ClockID]*Subsystem),
+		pins:       make(map[ClockID]map[string]*PinInfo),
+	}
+
+	for si := range cc.Structure {
+		subsystem := &cc.Structure[si]
+		if subsystem.DPLL.ClockID == 0 {
+			continue
+		}
+		clockID := subsystem.DPLL.ClockID
+		if _, exists := pi.subsystems[clockID]; exists {
+			// Duplicate clock IDs are reported by validation; the first subsystem wins
+			continue
+		}
+		pi.subsystems[clockID] = subsystem
+		pins := make(map[string]*PinInfo)
+		pi.pins[clockID] = pins
+
+		for _, group := range pinGroups {
+			groupPins := group.pins(&subsystem.DPLL)
+			for label := range groupPins {
+				config := groupPins[label]
+				pins[label] = &PinInfo{
+					SubsystemIndex: si,
+					Subsystem:      subsystem,
+					BoardLabel:     label,
+					Group:          group.name,
+					Direction:      group.direction,
+					Kind:           group.kind,
+					Config:         &config,
+				}
+			}
+		}
+
+		if pm == nil || subsystem.HardwarePlugin == "" {
+			continue
+		}
+		if plugin := pm.GetPlugin(subsystem.HardwarePlugin); plugin != nil {
+			for _, label := range plugin.PinLabels() {
+				if _, declared := pins[label]; !declared {
+					caps := plugin.Pins[label]
+					pins[label] = &PinInfo{
+						SubsystemIndex: si,
+						Subsystem:      subsystem,
+						BoardLabel:     label,
+						Direction:      caps.Direction,
+						Kind:           caps.Kind,
+					}
+				}
+			}
+		}
+	}
+
+	return pi
+}
+
+// Subsystem returns the subsystem whose DPLL has the given clock ID, or nil if there is none
+func (pi *PinIndex) Subsystem(clockID ClockID) *Subsystem {
+	return pi.subsystems[clockID]
+}
+
+// Lookup resolves a pin by clock ID and board label
+func (pi *PinIndex) Lookup(clockID ClockID, boardLabel string) (*PinInfo, bool) {
+	pin, ok := pi.pins[clockID][boardLabel]
+	return pin, ok
+}
+
+// Labels returns the board labels known for a clock ID, sorted
+func (pi *PinIndex) Labels(clockID ClockID) []string {
+	pins := pi.pins[clockID]
+	labels := make([]string, 0, len(pins))
+	for label := range pins {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DisabledPriority is the input priority used to take an input pin out of the selection
+const DisabledPriority = 255
+
+// PinKey identifies a pin by clock ID and board label
+type PinKey struct {
+	ClockID    ClockID
+	BoardLabel string
+}
+
+// NewPinKey returns the key of a pin
+func NewPinKey(clockID ClockID, boardLabel string) PinKey {
+	return PinKey{ClockID: clockID, BoardLabel: boardLabel}
+}
+
+func (k PinKey) String() string {
+	return k.ClockID.String() + ":" + k.BoardLabel
+}
+
+// PinSettings is the effective state of a pin in the EEC and PPS DPLLs.
+// Fields that no desired state has set yet are left empty.
+type PinSettings struct {
+	EEC PinState
+	PPS PinState
+}
+
+// PinTable maps pins to their effective settings after desired states have been applied
+type PinTable map[PinKey]PinSettings
+
+// Apply overlays desired states on the table in the order they are listed. Only the fields a
+// desired state sets are changed, the remaining settings of the pin are kept.
+func (t PinTable) Apply(states []DesiredState) {
+	for _, ds := range states {
+		key := NewPinKey(ds.ClockID, ds.BoardLabel)
+		settings := t[key]
+		overlayPinState(&settings.EEC, ds.EEC)
+		overlayPinState(&settings.PPS, ds.PPS)
+		t[key] = settings
+	}
+}
+
+// overlayPinState copies the fields set in src over dst
+func overlayPinState(dst *PinState, src *PinState) {
+	if src == nil {
+		return
+	}
+	if src.Priority != nil {
+		priority := *src.Priority
+		dst.Priority = &priority
+	}
+	if src.State != "" {
+		dst.State = src.State
+	}
+}
+
+// Clone returns an independent copy of the table
+func (t PinTable) Clone() PinTable {
+	clone := make(PinTable, len(t))
+	for key, settings := range t {
+		clone[key] = settings.clone()
+	}
+	return clone
+}
+
+func (s PinSettings) clone() PinSettings {
+	return PinSettings{EEC: s.EEC.clone(), PPS: s.PPS.clone()}
+}
+
+func (ps PinState) clone() PinState {
+	if ps.Priority != nil {
+		priority := *ps.Priority
+		ps.Priority = &priority
+	}
+	return ps
+}
+
+// Keys returns the pins of the table in a stable order
+func (t PinTable) Keys() []PinKey {
+	keys := make([]PinKey, 0, len(t))
+	for key := range t {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ClockID != keys[j].ClockID {
+			return keys[i].ClockID < keys[j].ClockID
+		}
+		return keys[i].BoardLabel < keys[j].BoardLabel
+	})
+	return keys
+}
+
+// InputEnabled reports whether an input pin can be selected by at least one DPLL, i.e. it is not
+// disconnected or set to DisabledPriority in both. Pins that were never set are considered enabled.
+func (t PinTable) InputEnabled(key PinKey) bool {
+	settings := t[key]
+	return !inputDisabled(settings.EEC) || !inputDisabled(settings.PPS)
+}
+
+func inputDisabled(ps PinState) bool {
+	return ps.State == PinStateDisconnected || (ps.Priority != nil && *ps.Priority >= DisabledPriority)
+}
+
+// OutputEnabled reports whether an output pin is driven by at least one DPLL, i.e. it is not
+// disconnected in both. Pins that were never set are considered enabled.
+func (t PinTable) OutputEnabled(key PinKey) bool {
+	settings := t[key]
+	return settings.EEC.State != PinStateDisconnected || settings.PPS.State != PinStateDisconnected
+}
+
+// String formats the pin state for tables and diffs, e.g. "prio 0" or "connected"
+func (ps PinState) String() string {
+	var parts []string
+	if ps.Priority != nil {
+		parts = append(parts, fmt.Sprintf("prio %g", *ps.Priority))
+	}
+	if ps.State != "" {
+		parts = append(parts, string(ps.State))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Equal reports whether two pin states set the same fields to the same values
+func (ps PinState) Equal(other PinState) bool {
+	if (ps.Priority == nil) != (other.Priority == nil) {
+		return false
+	}
+	if ps.Priority != nil && *ps.Priority != *other.Priority {
+		return false
+	}
+	return ps.State == other.State
+}
+
+// isDefaultCondition reports whether a condition initializes the hardware on profile (re)load
+func isDefaultCondition(condition *Condition) bool {
+	return len(condition.Sources) > 0 && condition.Sources[0].ConditionType == ConditionTypeDefault
+}
+
+// isInitCondition reports whether a condition prepares the hardware for the Acquiring state
+func isInitCondition(condition *Condition) bool {
+	return len(condition.Sources) > 0 && condition.Sources[0].ConditionType == ConditionTypeInit
+}
+
+// DefaultPinTable returns the pin table resulting from applying the desired states of all
+// "default" conditions, in the order the conditions are listed
+func (cc *ClockChain) DefaultPinTable() PinTable {
+	table := make(PinTable)
+	cc.applyConditions(table, isDefaultCondition)
+	return table
+}
+
+// InitialPinTable returns the pin table of the hardware before any source locks: the "default"
+// conditions followed by the "init" condition. Validation requires them to be listed in that order.
+func (cc *ClockChain) InitialPinTable() PinTable {
+	table := cc.DefaultPinTable()
+	cc.applyConditions(table, isInitCondition)
+	return table
+}
+
+// ConditionPinTable returns the pin table of the hardware in a condition. The state of a "default"
+// condition is the default pin table and the state of an "init" condition the initial pin table; any
+// other condition is applied on top of the initial pin table.
+func (cc *ClockChain) ConditionPinTable(condition *Condition) PinTable {
+	switch {
+	case isDefaultCondition(condition):
+		return cc.DefaultPinTable()
+	case isInitCondition(condition):
+		return cc.InitialPinTable()
+	}
+	table := cc.InitialPinTable()
+	table.Apply(condition.DesiredStates)
+	return table
+}
+
+// applyConditions applies the desired states of the selected conditions to a table, in the order the
+// conditions are listed
+func (cc *ClockChain) applyConditions(table PinTable, selected func(*Condition) bool) {
+	if cc.Behavior == nil {
+		return
+	}
+	for i := range cc.Behavior.Conditions {
+		if selected(&cc.Behavior.Conditions[i]) {
+			table.Apply(cc.Behavior.Conditions[i].DesiredStates)
+		}
+	}
+}
+
+// PinChange is the change of a pin setting in one DPLL between two pin tables
+type PinChange struct {
+	Key PinKey
+
+	// DPLL is "eec" or "pps"
+	DPLL string
+
+	Before PinState
+	After  PinState
+}
+
+func (c PinChange) String() string {
+	return fmt.Sprintf("%s %s: %s -> %s", c.Key, c.DPLL, c.Before, c.After)
+}
+
+// Diff returns the settings that differ between the table and a later table, ordered by pin and DPLL
+func (t PinTable) Diff(after PinTable) []PinChange {
+	keys := make(map[PinKey]bool)
+	for key := range t {
+		keys[key] = true
+	}
+	for key := range after {
+		keys[key] = true
+	}
+	union := make(PinTable, len(keys))
+	for key := range keys {
+		union[key] = PinSettings{}
+	}
+
+	var changes []PinChange
+	for _, key := range union.Keys() {
+		before, now := t[key], after[key]
+		if !before.EEC.Equal(now.EEC) {
+			changes = append(changes, PinChange{Key: key, DPLL: "eec", Before: before.EEC, After: now.EEC})
+		}
+		if !before.PPS.Equal(now.PPS) {
+			changes = append(changes, PinChange{Key: key, DPLL: "pps", Before: before.PPS, After: now.PPS})
+		}
+	}
+	return changes
+}
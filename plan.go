@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// PlanFormat selects how a plan is printed
+type PlanFormat string
+
+const (
+	PlanFormatTable  PlanFormat = "table"
+	PlanFormatJSON   PlanFormat = "json"
+	PlanFormatScript PlanFormat = "ynl"
+)
+
+// PlanStep is an operation of a plan together with the configuration entry it originates from
+type PlanStep struct {
+	PinOperation
+
+	// Origin is the path of the pin config or desired state the operation was derived from
+	Origin string `json:"origin"`
+
+	// Phase is set for the steps of a transition between conditions
+	Phase TransitionPhase `json:"phase,omitempty"`
+
+	// Loops are the timing loops active after the step of a transition, if any
+	Loops []string `json:"loops,omitempty"`
+}
+
+// Plan is the ordered list of DPLL operations that applies a condition to the hardware, without
+// touching it
+type Plan struct {
+	// From is the name of the condition a transition starts from, empty if the plan is not a transition
+	From string `json:"from,omitempty"`
+
+	// Condition is the name of the planned condition
+	Condition string `json:"condition"`
+
+	// Steps are the operations in the order they are applied: pin configurations first, then the
+	// desired states of the condition in the order they are listed. Transitions order break
+	// operations before make operations instead.
+	Steps []PlanStep `json:"steps"`
+}
+
+// BuildPlan resolves a merged clock chain and a condition into a plan. If conditionName is empty,
+// the first "default" condition is planned. Pin configuration operations are included if
+// withPinConfig is set.
+func BuildPlan(cc *ClockChain, conditionName string, withPinConfig bool) (*Plan, error) {
+	condition, ci, err := findPlanCondition(cc, conditionName)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Condition: condition.Name}
+
+	if withPinConfig {
+		ops, err := PinConfigOperations(cc)
+		if err != nil {
+			return nil, err
+		}
+		pins := NewPinIndex(cc, nil)
+		for _, op := range ops {
+			origin := ""
+			if pin, ok := pins.Lookup(op.ClockID, op.BoardLabel); ok {
+				origin = pin.path()
+			}
+			plan.Steps = append(plan.Steps, PlanStep{PinOperation: op, Origin: origin})
+		}
+	}
+
+	conditionPath := cc.conditionPath(ci)
+	for di, ds := range condition.DesiredStates {
+		ops, err := DesiredStateOperations([]DesiredState{ds})
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", condition.Name, err)
+		}
+		statePath := pathIndex(conditionPath+".desiredStates", di)
+		for _, op := range ops {
+			plan.Steps = append(plan.Steps, PlanStep{PinOperation: op, Origin: statePath + "." + string(op.DPLLType)})
+		}
+	}
+	return plan, nil
+}
+
+// findPlanCondition returns the named condition and its index, or the first "default" condition
+// if the name is empty
+func findPlanCondition(cc *ClockChain, name string) (*Condition, int, error) {
+	if cc.Behavior == nil {
+		return nil, 0, fmt.Errorf("configuration has no behavior section")
+	}
+	for i := range cc.Behavior.Conditions {
+		condition := &cc.Behavior.Conditions[i]
+		if (name == "" && isDefaultCondition(condition)) || (name != "" && condition.Name == name) {
+			return condition, i, nil
+		}
+	}
+	if name == "" {
+		return nil, 0, fmt.Errorf("configuration has no default condition")
+	}
+	return nil, 0, fmt.Errorf("condition %q not found (available: %s)", name, strings.Join(cc.ConditionNames(), ", "))
+}
+
+// Format renders the plan in the given format
+func (p *Plan) Format(format PlanFormat) (string, error) {
+	switch format {
+	case PlanFormatTable, "":
+		return p.table(), nil
+	case PlanFormatJSON:
+		data, err := json.MarshalIndent(p, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	case PlanFormatScript:
+		return p.script()
+	}
+	return "", fmt.Errorf("unknown plan format %q (supported: %s, %s, %s)", format, PlanFormatTable, PlanFormatJSON, PlanFormatScript)
+}
+
+// table renders the plan as a human readable table
+func (p *Plan) table() string {
+	var sb strings.Builder
+	if p.From != "" {
+		fmt.Fprintf(&sb, "Transition from %q to %q: %d operation(s)\n\n", p.From, p.Condition, len(p.Steps))
+	} else {
+		fmt.Fprintf(&sb, "Plan for condition %q: %d operation(s)\n\n", p.Condition, len(p.Steps))
+	}
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "#\tCLOCK ID\tBOARD LABEL\tDPLL\tSETTINGS\tORIGIN")
+	for i, step := range p.Steps {
+		dpllType := string(step.DPLLType)
+		if dpllType == "" {
+			dpllType = "-"
+		}
+		settings := strings.Join(step.settings(), " ")
+		if step.Phase != "" {
+			settings = fmt.Sprintf("%s (%s)", settings, step.Phase)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\n", i+1, step.ClockID, step.BoardLabel, dpllType, settings, step.Origin)
+	}
+	w.Flush()
+	for i, step := range p.Steps {
+		for _, loop := range step.Loops {
+			fmt.Fprintf(&sb, "\nWarning: timing loop active after step %d: %s", i+1, loop)
+		}
+	}
+	if p.Unsafe() {
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// settings returns the attributes set by the operation as "name value" pairs, using the DPLL
+// netlink attribute names
+func (op PinOperation) settings() []string {
+	var settings []string
+	if op.Priority != nil {
+		settings = append(settings, fmt.Sprintf("prio %d", *op.Priority))
+	}
+	if op.State != "" {
+		settings = append(settings, "state "+op.State)
+	}
+	if op.Frequency != nil {
+		settings = append(settings, fmt.Sprintf("frequency %d", *op.Frequency))
+	}
+	if op.ESyncFrequency != nil {
+		settings = append(settings, fmt.Sprintf("esync-frequency %d", *op.ESyncFrequency))
+	}
+	if op.PhaseAdjust != nil {
+		settings = append(settings, fmt.Sprintf("phase-adjust %d", *op.PhaseAdjust))
+	}
+	return settings
+}
+
+// planScriptHeader resolves pin and device IDs at run time, as they are assigned by the driver
+const planScriptHeader = `#!/bin/sh
+# Generated by ptp-config-parser plan. Requires ynl (tools/net/ynl) and jq.
+set -eu
+
+YNL="${YNL:-ynl --family dpll}"
+
+# pin_id <clock-id> <JSON quoted board-label>
+pin_id() {
+	$YNL --do pin-id-get --json "{\"clock-id\": $1, \"board-label\": $2}" --output-json | jq -r .id
+}
+
+# device_id <clock-id> <eec|pps>
+device_id() {
+	$YNL --do device-id-get --json "{\"clock-id\": $1, \"type\": \"$2\"}" --output-json | jq -r .id
+}
+
+# pin_set <json>
+pin_set() {
+	$YNL --do pin-set --json "$1"
+}
+`
+
+// script renders the plan as a shell script of ynl commands
+func (p *Plan) script() (string, error) {
+	var sb strings.Builder
+	sb.WriteString(planScriptHeader)
+	if p.From != "" {
+		fmt.Fprintf(&sb, "\n# Transition from: %s\n", strings.ReplaceAll(p.From, "\n", " "))
+	}
+	fmt.Fprintf(&sb, "\n# Condition: %s\n", strings.ReplaceAll(p.Condition, "\n", " "))
+	for i, step := range p.Steps {
+		clockID := step.ClockID
+		fmt.Fprintf(&sb, "\n# %d. %s\n", i+1, step.PinOperation)
+		for _, loop := range step.Loops {
+			fmt.Fprintf(&sb, "# WARNING: timing loop active after this step: %s\n", loop)
+		}
+
+		label, err := json.Marshal(step.BoardLabel)
+		if err != nil {
+			return "", err
+		}
+		attrs := []string{fmt.Sprintf(`"id": '"$(pin_id %d %s)"'`, uint64(clockID), shellQuote(string(label)))}
+		if step.Frequency != nil {
+			attrs = append(attrs, fmt.Sprintf(`"frequency": %d`, *step.Frequency))
+		}
+		if step.ESyncFrequency != nil {
+			attrs = append(attrs, fmt.Sprintf(`"esync-frequency": %d`, *step.ESyncFrequency))
+		}
+		if step.PhaseAdjust != nil {
+			attrs = append(attrs, fmt.Sprintf(`"phase-adjust": %d`, *step.PhaseAdjust))
+		}
+		if step.DPLLType != "" {
+			parent := []string{fmt.Sprintf(`"parent-id": '"$(device_id %d %s)"'`, uint64(clockID), step.DPLLType)}
+			if step.Priority != nil {
+				parent = append(parent, fmt.Sprintf(`"prio": %d`, *step.Priority))
+			}
+			if step.State != "" {
+				parent = append(parent, fmt.Sprintf(`"state": "%s"`, step.State))
+			}
+			attrs = append(attrs, `"parent-device": {`+strings.Join(parent, ", ")+`}`)
+		}
+		fmt.Fprintf(&sb, "pin_set '{%s}'\n", strings.Join(attrs, ", "))
+	}
+	return sb.String(), nil
+}
+
+// shellQuote quotes a string for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// runPlan implements the plan subcommand
+func runPlan(args []string) int {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	condition := flags.String("condition", "", "condition to plan (default: the default condition)")
+	from := flags.String("from", "", "plan the transition from this condition to --condition instead")
+	format := flags.String("format", string(PlanFormatTable), "output format: table, json or ynl")
+	pinConfig := flags.Bool("pin-config", true, "include frequency, phase adjustment and eSync operations")
+	output := flags.String("o", "", "output file (default: stdout)")
+	report := addReportFlags(flags)
+	addLoadFlags(flags, true)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser plan [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded := loadForCommand(flags.Arg(0), report)
+	if loaded == nil {
+		return 1
+	}
+	var plan *Plan
+	var err error
+	if *from != "" {
+		plan, err = BuildTransitionPlan(loaded.Config, *from, *condition)
+	} else {
+		plan, err = BuildPlan(loaded.Config, *condition, *pinConfig)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	rendered, err := plan.Format(PlanFormat(*format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if err := writeOutput(*output, rendered); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 
 	"gopkg.in/yaml.v3"
 )
@@ -57,15 +58,30 @@ func (pm *PluginManager) LoadPlugin(pluginPath string) error {
 		return fmt.Errorf("failed to read plugin file: %w", err)
 	}
 
+	// Decode strictly, so that a misspelled key does not silently drop pin defaults or capabilities
+	var root yaml.Node
 	var plugin HardwarePluginConfig
-	if err := yaml.Unmarshal(data, &plugin); err != nil {
+	if err := yaml.Unmarshal(data, &root); err != nil {
 		return fmt.Errorf("failed to parse plugin YAML: %w", err)
 	}
+	if root.Kind != 0 {
+		if err := decodeStrict(&root, &plugin); err != nil {
+			return fmt.Errorf("failed to parse plugin YAML: %w", err)
+		}
+	}
 
 	// Validate plugin has required fields
 	if plugin.PluginInfo.Name == "" {
 		return fmt.Errorf("plugin must have a name")
 	}
+	for label, caps := range plugin.Pins {
+		if err := caps.validate(label); err != nil {
+			return fmt.Errorf("invalid pin capabilities: %w", err)
+		}
+	}
+	if err := plugin.validateDefaults(); err != nil {
+		return fmt.Errorf("invalid pin defaults: %w", err)
+	}
 
 	// Store plugin by name
 	pm.plugins[plugin.PluginInfo.Name] = &plugin
@@ -77,6 +93,37 @@ func (pm *PluginManager) GetPlugin(name string) *HardwarePluginConfig {
 	return pm.plugins[name]
 }
 
+// PinLabels returns the board labels of all pins the plugin knows about, either through
+// capabilities or defaults, sorted
+func (p *HardwarePluginConfig) PinLabels() []string {
+	known := make(map[string]bool)
+	for label := range p.Pins {
+		known[label] = true
+	}
+	for label := range p.SpecificDefaults {
+		known[label] = true
+	}
+	for label := range p.InitDefaults {
+		known[label] = true
+	}
+	labels := make([]string, 0, len(known))
+	for label := range known {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
+// Labels returns the board labels of the pins with defaults, sorted
+func (d PluginSpecificDefaults) Labels() []string {
+	labels := make([]string, 0, len(d))
+	for label := range d {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
+}
+
 // ListPlugins returns a list of all loaded plugin names
 func (pm *PluginManager) ListPlugins() []string {
 	var names []string
@@ -87,19 +134,21 @@ func (pm *PluginManager) ListPlugins() []string {
 }
 
 // ApplyPluginDefaults applies hardware plugin defaults to a condition's desired states
-// This function merges plugin defaults with user-specified desired states
+// This function merges plugin defaults with user-specified desired states.
+// "default" conditions receive the plugin specificDefaults, "init" conditions the plugin initDefaults.
 func (pm *PluginManager) ApplyPluginDefaults(clockChain *ClockChain, condition *Condition) error {
-	// Only apply defaults for "default" condition type
-	if len(condition.Sources) == 0 || condition.Sources[0].ConditionType != "default" {
+	// Only apply defaults for "default" and "init" condition types
+	if !isDefaultCondition(condition) && !isInitCondition(condition) {
 		return nil
 	}
 
 	// If user has already specified desired states, we'll merge with plugin defaults
-	// Build a map of existing desired states by clockID+boardLabel for quick lookup
-	existingStates := make(map[string]*DesiredState)
+	// Build a map of existing desired state indexes by clockID+boardLabel for quick lookup.
+	// Indexes rather than pointers, as appending plugin defaults may reallocate the slice.
+	existingStates := make(map[PinKey]int)
 	for i := range condition.DesiredStates {
-		key := condition.DesiredStates[i].ClockID + ":" + condition.DesiredStates[i].BoardLabel
-		existingStates[key] = &condition.DesiredStates[i]
+		key := NewPinKey(condition.DesiredStates[i].ClockID, condition.DesiredStates[i].BoardLabel)
+		existingStates[key] = i
 	}
 
 	// Process each subsystem and apply plugin defaults
@@ -114,8 +163,13 @@ func (pm *PluginManager) ApplyPluginDefaults(clockChain *ClockChain, condition *
 			continue
 		}
 
+		defaults := plugin.SpecificDefaults
+		if isInitCondition(condition) {
+			defaults = plugin.InitDefaults
+		}
+
 		// Apply defaults for all pins in this subsystem
-		if err := pm.applySubsystemDefaults(subsystem, plugin, existingStates, &condition.DesiredStates); err != nil {
+		if err := pm.applySubsystemDefaults(subsystem, defaults, existingStates, &condition.DesiredStates); err != nil {
 			return fmt.Errorf("failed to apply defaults for subsystem %s: %w", subsystem.Name, err)
 		}
 	}
@@ -128,17 +182,20 @@ func (pm *PluginManager) ApplyPluginDefaults(clockChain *ClockChain, condition *
 // then user config can overlay/override specific settings
 func (pm *PluginManager) applySubsystemDefaults(
 	subsystem Subsystem,
-	plugin *HardwarePluginConfig,
-	existingStates map[string]*DesiredState,
+	defaults PluginSpecificDefaults,
+	existingStates map[PinKey]int,
 	desiredStates *[]DesiredState,
 ) error {
 	// Apply defaults for ALL pins defined in the plugin, not just those in user config
-	// This creates a base configuration that user config can then overlay
-	for boardLabel, specificDefaults := range plugin.SpecificDefaults {
-		key := subsystem.DPLL.ClockID + ":" + boardLabel
+	// This creates a base configuration that user config can then overlay.
+	// Pins are visited by board label, so that the generated desired states are listed in a stable order.
+	for _, boardLabel := range defaults.Labels() {
+		specificDefaults := defaults[boardLabel]
+		key := NewPinKey(subsystem.DPLL.ClockID, boardLabel)
 
 		// Check if user has already specified this pin
-		if existingState, exists := existingStates[key]; exists {
+		if index, exists := existingStates[key]; exists {
+			existingState := &(*desiredStates)[index]
 			// User has specified this pin - merge/overlay user settings on top of plugin defaults
 			// User settings take precedence, but we fill in missing fields with plugin defaults
 			if existingState.EEC == nil && specificDefaults.EEC != nil {
@@ -176,7 +233,7 @@ func (pm *PluginManager) applySubsystemDefaults(
 			// Add the new state if we created any pin configurations
 			if newState.EEC != nil || newState.PPS != nil {
 				*desiredStates = append(*desiredStates, newState)
-				existingStates[key] = &(*desiredStates)[len(*desiredStates)-1]
+				existingStates[key] = len(*desiredStates) - 1
 			}
 		}
 	}
@@ -191,13 +248,11 @@ func (pm *PluginManager) MergeUserConfigWithDefaults(clockChain *ClockChain) err
 		return nil // No behavior section, nothing to merge
 	}
 
-	// Check if there's already a default condition
-	hasDefaultCondition := false
-	for _, condition := range clockChain.Behavior.Conditions {
-		if len(condition.Sources) > 0 && condition.Sources[0].ConditionType == "default" {
-			hasDefaultCondition = true
-			break
-		}
+	// Check if there's already a default and an init condition
+	hasDefaultCondition, hasInitCondition := false, false
+	for i := range clockChain.Behavior.Conditions {
+		hasDefaultCondition = hasDefaultCondition || isDefaultCondition(&clockChain.Behavior.Conditions[i])
+		hasInitCondition = hasInitCondition || isInitCondition(&clockChain.Behavior.Conditions[i])
 	}
 
 	// If no default condition exists, create one with plugin defaults
@@ -206,17 +261,42 @@ func (pm *PluginManager) MergeUserConfigWithDefaults(clockChain *ClockChain) err
 			Name: "Default Configuration (Auto-generated)",
 			Sources: []SourceState{
 				{
-					SourceName:    "Default on profile (re)load",
-					ConditionType: "default",
+					SourceName:    DefaultSourceName,
+					ConditionType: ConditionTypeDefault,
 				},
 			},
 			DesiredStates: []DesiredState{}, // Will be populated by ApplyPluginDefaults
+			generated:     true,
 		}
 
 		// Add the default condition to the beginning of the conditions list
 		clockChain.Behavior.Conditions = append([]Condition{defaultCondition}, clockChain.Behavior.Conditions...)
 	}
 
+	// If no init condition exists but a plugin in use has init defaults, create one for them
+	if !hasInitCondition && pm.hasInitDefaults(clockChain) {
+		initCondition := Condition{
+			Name: "Init Configuration (Auto-generated)",
+			Sources: []SourceState{
+				{
+					SourceName:    InitSourceName,
+					ConditionType: ConditionTypeInit,
+				},
+			},
+			DesiredStates: []DesiredState{}, // Will be populated by ApplyPluginDefaults
+			generated:     true,
+		}
+
+		// Insert the init condition after the default conditions, where the condition order requires it
+		position := 0
+		for position < len(clockChain.Behavior.Conditions) && isDefaultCondition(&clockChain.Behavior.Conditions[position]) {
+			position++
+		}
+		conditions := append([]Condition{}, clockChain.Behavior.Conditions[:position]...)
+		conditions = append(conditions, initCondition)
+		clockChain.Behavior.Conditions = append(conditions, clockChain.Behavior.Conditions[position:]...)
+	}
+
 	// Apply plugin defaults to each condition
 	for i := range clockChain.Behavior.Conditions {
 		if err := pm.ApplyPluginDefaults(clockChain, &clockChain.Behavior.Conditions[i]); err != nil {
@@ -227,3 +307,13 @@ func (pm *PluginManager) MergeUserConfigWithDefaults(clockChain *ClockChain) err
 
 	return nil
 }
+
+// hasInitDefaults reports whether the hardware plugin of any subsystem has init defaults
+func (pm *PluginManager) hasInitDefaults(clockChain *ClockChain) bool {
+	for _, subsystem := range clockChain.Structure {
+		if plugin := pm.GetPlugin(subsystem.HardwarePlugin); plugin != nil && len(plugin.InitDefaults) > 0 {
+			return true
+		}
+	}
+	return false
+}
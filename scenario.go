@@ -0,0 +1,290 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a timeline of source transitions used to simulate the behavior section of a configuration
+type Scenario struct {
+	// Name describes the scenario
+	Name string `yaml:"name,omitempty"`
+
+	// Steps are the events in the order they happen
+	Steps []ScenarioStep `yaml:"steps"`
+}
+
+// ScenarioStep is a single event of a scenario, with optional expectations on its outcome
+type ScenarioStep struct {
+	// At is the time of the event in seconds from the start of the scenario. It is only used for display.
+	At float64 `yaml:"at"`
+
+	// Event is "<source> locked", "<source> lost", or "default" to reload the profile
+	Event string `yaml:"event"`
+
+	// Expect holds the assertions checked after the event, if any
+	Expect *ScenarioExpectation `yaml:"expect,omitempty"`
+}
+
+// ScenarioExpectation asserts the outcome of a scenario step. Only the fields that are set are checked.
+type ScenarioExpectation struct {
+	// Condition is the name of the condition expected to fire. An empty string asserts that no condition fires.
+	Condition *string `yaml:"condition,omitempty"`
+
+	// ActiveSource is the source expected to discipline the clock chain, or an empty string for none
+	ActiveSource *string `yaml:"activeSource,omitempty"`
+
+	// Holdover asserts whether the chain enters holdover
+	Holdover *bool `yaml:"holdover,omitempty"`
+
+	// Pins are the expected pin settings after the event. Clock IDs may use aliases. Only the priorities
+	// and states that are set are compared.
+	Pins []ScenarioPin `yaml:"pins,omitempty"`
+}
+
+// ScenarioPin is the expected setting of a pin after a scenario step
+type ScenarioPin struct {
+	// ClockID is the subsystem clock ID (decimal, hex or alias), resolved against the clock identifiers
+	// of the configuration
+	ClockID string `yaml:"clockId"`
+
+	BoardLabel string `yaml:"boardLabel"`
+
+	// EEC and PPS are the expected states of the pin in each DPLL
+	EEC *PinState `yaml:"eec,omitempty"`
+	PPS *PinState `yaml:"pps,omitempty"`
+}
+
+// ScenarioResult is the outcome of a scenario step
+type ScenarioResult struct {
+	Step ScenarioStep
+
+	// Condition is the condition that fired, or nil
+	Condition *Condition
+
+	// ActiveSource, Failover and Holdover are copied from the engine step
+	ActiveSource string
+	Failover     bool
+	Holdover     bool
+
+	// Changes are the pin settings changed by the step
+	Changes []PinChange
+
+	// Failures describe the expectations that were not met
+	Failures []string
+}
+
+// LoadScenario reads a scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var scenario Scenario
+	if err := yaml.Unmarshal(data, &scenario); err != nil {
+		return nil, fmt.Errorf("parsing scenario %s: %w", path, err)
+	}
+	if len(scenario.Steps) == 0 {
+		return nil, fmt.Errorf("scenario %s has no steps", path)
+	}
+	return &scenario, nil
+}
+
+// parseScenarioEvent parses "<source> locked", "<source> lost" or "default". Source names may contain spaces.
+func parseScenarioEvent(event string) (SourceEvent, bool, error) {
+	event = strings.TrimSpace(event)
+	if event == "default" {
+		return SourceEvent{}, true, nil
+	}
+	split := strings.LastIndex(event, " ")
+	if split < 0 {
+		return SourceEvent{}, false, fmt.Errorf("invalid event %q, expected \"<source> locked\", \"<source> lost\" or \"default\"", event)
+	}
+	return SourceEvent{
+		Source: strings.TrimSpace(event[:split]),
+		Status: SourceStatus(event[split+1:]),
+	}, false, nil
+}
+
+// RunScenario simulates a scenario against a merged clock chain. It returns an error if an event
+// is invalid; unmet expectations are reported as failures of the step results.
+func RunScenario(cc *ClockChain, scenario *Scenario) ([]ScenarioResult, error) {
+	engine, err := NewEngine(cc)
+	if err != nil {
+		return nil, err
+	}
+	aliases, err := cc.BuildClockAliasMap()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]ScenarioResult, 0, len(scenario.Steps))
+	for i, step := range scenario.Steps {
+		event, reload, err := parseScenarioEvent(step.Event)
+		if err != nil {
+			return nil, fmt.Errorf("steps[%d]: %w", i, err)
+		}
+
+		result := ScenarioResult{Step: step}
+		if reload {
+			before := engine.Table()
+			engine.Reset()
+			result.Changes = before.Diff(engine.Table())
+		} else {
+			engineStep, err := engine.Handle(event)
+			if err != nil {
+				return nil, fmt.Errorf("steps[%d]: %w", i, err)
+			}
+			result.Condition = engineStep.Condition
+			result.ActiveSource = engineStep.ActiveSource
+			result.Failover = engineStep.Failover
+			result.Holdover = engineStep.Holdover
+			result.Changes = engineStep.Changes
+		}
+
+		if step.Expect != nil {
+			result.Failures, err = step.Expect.check(&result, engine.Table(), aliases)
+			if err != nil {
+				return nil, fmt.Errorf("steps[%d].expect: %w", i, err)
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// check compares a step result and the resulting pin table with the expectation
+func (x *ScenarioExpectation) check(result *ScenarioResult, table PinTable, aliases map[string]ClockID) ([]string, error) {
+	var failures []string
+	if x.Condition != nil {
+		fired := ""
+		if result.Condition != nil {
+			fired = result.Condition.Name
+		}
+		if fired != *x.Condition {
+			failures = append(failures, fmt.Sprintf("expected condition %q, got %q", *x.Condition, fired))
+		}
+	}
+	if x.ActiveSource != nil && result.ActiveSource != *x.ActiveSource {
+		failures = append(failures, fmt.Sprintf("expected active source %q, got %q", *x.ActiveSource, result.ActiveSource))
+	}
+	if x.Holdover != nil && result.Holdover != *x.Holdover {
+		failures = append(failures, fmt.Sprintf("expected holdover %v, got %v", *x.Holdover, result.Holdover))
+	}
+
+	for pi, pin := range x.Pins {
+		clockID, err := resolveClockIDValue(pin.ClockID, aliases)
+		if err != nil {
+			return nil, fmt.Errorf("pins[%d].clockId: %w", pi, err)
+		}
+		key := NewPinKey(clockID, pin.BoardLabel)
+		actual := table[key]
+		for _, dpll := range []struct {
+			name     string
+			expected *PinState
+			actual   PinState
+		}{{"eec", pin.EEC, actual.EEC}, {"pps", pin.PPS, actual.PPS}} {
+			if dpll.expected == nil {
+				continue
+			}
+			if dpll.expected.Priority != nil &&
+				(dpll.actual.Priority == nil || *dpll.actual.Priority != *dpll.expected.Priority) {
+				failures = append(failures, fmt.Sprintf("expected %s %s priority %g, got %s",
+					key, dpll.name, *dpll.expected.Priority, dpll.actual))
+			}
+			if dpll.expected.State != "" && dpll.actual.State != dpll.expected.State {
+				failures = append(failures, fmt.Sprintf("expected %s %s state %s, got %s",
+					key, dpll.name, dpll.expected.State, dpll.actual))
+			}
+		}
+	}
+	return failures, nil
+}
+
+// printScenarioResults writes the simulation report and returns the number of failed expectations
+func printScenarioResults(w io.Writer, results []ScenarioResult) int {
+	failed := 0
+	for _, result := range results {
+		at := "t=" + strconv.FormatFloat(result.Step.At, 'f', -1, 64)
+		switch {
+		case result.Condition != nil:
+			fmt.Fprintf(w, "%-8s %s -> condition %q", at, result.Step.Event, result.Condition.Name)
+		case result.Step.Event == "default":
+			fmt.Fprintf(w, "%-8s default -> profile (re)load", at)
+		default:
+			fmt.Fprintf(w, "%-8s %s -> no condition", at, result.Step.Event)
+		}
+		var notes []string
+		if result.ActiveSource != "" {
+			notes = append(notes, "active source "+result.ActiveSource)
+		}
+		if result.Failover {
+			notes = append(notes, "failover")
+		}
+		if result.Holdover {
+			notes = append(notes, "holdover")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(w, " (%s)", strings.Join(notes, ", "))
+		}
+		fmt.Fprintln(w)
+
+		for _, change := range result.Changes {
+			fmt.Fprintf(w, "           %v\n", change)
+		}
+		for _, failure := range result.Failures {
+			fmt.Fprintf(w, "  FAIL     %s\n", failure)
+		}
+		failed += len(result.Failures)
+	}
+	return failed
+}
+
+// runSimulate implements the simulate subcommand
+func runSimulate(args []string) int {
+	flags := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	report := addReportFlags(flags)
+	addLoadFlags(flags, false)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser simulate <config-file> <scenario-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded := loadForCommand(flags.Arg(0), report)
+	if loaded == nil {
+		return 1
+	}
+	scenario, err := LoadScenario(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	results, err := RunScenario(loaded.Config, scenario)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if scenario.Name != "" {
+		fmt.Printf("Scenario: %s\n", scenario.Name)
+	}
+	if failed := printScenarioResults(os.Stdout, results); failed > 0 {
+		fmt.Printf("%d expectation(s) failed\n", failed)
+		return 1
+	}
+	fmt.Printf("%d step(s) simulated, all expectations met\n", len(results))
+	return 0
+}
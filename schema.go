@@ -0,0 +1,320 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// schemaRefPrefix is the only $ref form used by ptp-hw.yaml
+const schemaRefPrefix = "#/components/schemas/"
+
+// rootSchemaName is the component describing a complete configuration document
+const rootSchemaName = "ClockChain"
+
+// Schema is the subset of an OpenAPI 3.0 schema object needed to check clock chain documents.
+// Descriptive keywords (description, example, default, title) are parsed but not enforced.
+type Schema struct {
+	Ref                  string             `yaml:"$ref,omitempty"`
+	Type                 string             `yaml:"type,omitempty"`
+	Title                string             `yaml:"title,omitempty"`
+	Required             []string           `yaml:"required,omitempty"`
+	Properties           map[string]*Schema `yaml:"properties,omitempty"`
+	AdditionalProperties *Schema            `yaml:"additionalProperties,omitempty"`
+	Items                *Schema            `yaml:"items,omitempty"`
+	Enum                 []string           `yaml:"enum,omitempty"`
+	Pattern              string             `yaml:"pattern,omitempty"`
+	MinItems             *int               `yaml:"minItems,omitempty"`
+	Minimum              *float64           `yaml:"minimum,omitempty"`
+	OneOf                []*Schema          `yaml:"oneOf,omitempty"`
+	AnyOf                []*Schema          `yaml:"anyOf,omitempty"`
+	AllOf                []*Schema          `yaml:"allOf,omitempty"`
+	Not                  *Schema            `yaml:"not,omitempty"`
+
+	pattern *regexp.Regexp
+}
+
+// OpenAPISchema holds the component schemas of an OpenAPI document such as ptp-hw.yaml
+type OpenAPISchema struct {
+	Components struct {
+		Schemas map[string]*Schema `yaml:"schemas"`
+	} `yaml:"components"`
+}
+
+// embeddedSchema is the ptp-hw.yaml specification built into the binary
+//
+//go:embed ptp-hw.yaml
+var embeddedSchema []byte
+
+// DefaultSchema parses the ptp-hw.yaml specification built into the binary
+func DefaultSchema() (*OpenAPISchema, error) {
+	return ParseSchema(embeddedSchema)
+}
+
+// LoadSchema loads the OpenAPI component schemas from the specified file
+func LoadSchema(schemaPath string) (*OpenAPISchema, error) {
+	data, err := os.ReadFile(schemaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema file: %w", err)
+	}
+	return ParseSchema(data)
+}
+
+// ParseSchema parses an OpenAPI document and prepares its component schemas for validation
+func ParseSchema(data []byte) (*OpenAPISchema, error) {
+	var doc OpenAPISchema
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse schema YAML: %w", err)
+	}
+	if _, ok := doc.Components.Schemas[rootSchemaName]; !ok {
+		return nil, fmt.Errorf("schema does not define components.schemas.%s", rootSchemaName)
+	}
+
+	for name, schema := range doc.Components.Schemas {
+		if err := doc.compile(schema); err != nil {
+			return nil, fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+
+	return &doc, nil
+}
+
+// compile checks references and pre-compiles patterns for a schema and all of its subschemas
+func (s *OpenAPISchema) compile(schema *Schema) error {
+	if schema == nil {
+		return nil
+	}
+	if schema.Ref != "" {
+		if _, err := s.resolve(schema); err != nil {
+			return err
+		}
+	}
+	if schema.Pattern != "" {
+		re, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", schema.Pattern, err)
+		}
+		schema.pattern = re
+	}
+
+	children := []*Schema{schema.AdditionalProperties, schema.Items, schema.Not}
+	for _, prop := range schema.Properties {
+		children = append(children, prop)
+	}
+	children = append(children, schema.OneOf...)
+	children = append(children, schema.AnyOf...)
+	children = append(children, schema.AllOf...)
+	for _, child := range children {
+		if err := s.compile(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolve follows a $ref to the referenced component schema
+func (s *OpenAPISchema) resolve(schema *Schema) (*Schema, error) {
+	if schema.Ref == "" {
+		return schema, nil
+	}
+	if !strings.HasPrefix(schema.Ref, schemaRefPrefix) {
+		return nil, fmt.Errorf("unsupported $ref %q", schema.Ref)
+	}
+	target, ok := s.Components.Schemas[strings.TrimPrefix(schema.Ref, schemaRefPrefix)]
+	if !ok {
+		return nil, fmt.Errorf("unresolved $ref %q", schema.Ref)
+	}
+	return target, nil
+}
+
+// ValidateDocument checks a parsed clock chain document against the ClockChain component schema.
+// All violations are returned with the "schema" rule ID, ordered by their position in the document.
+func (s *OpenAPISchema) ValidateDocument(root *yaml.Node) ValidationErrors {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return ValidationErrors{{Severity: SeverityError, Rule: RuleSchema, Line: node.Line, Column: node.Column,
+				Message: "document is empty"}}
+		}
+		node = node.Content[0]
+	}
+
+	violations := s.validate(s.Components.Schemas[rootSchemaName], node, "")
+	violations.Sort()
+	return violations
+}
+
+// validate checks a single node against a schema and recurses into its children
+func (s *OpenAPISchema) validate(schema *Schema, node *yaml.Node, path string) ValidationErrors {
+	if schema == nil {
+		return nil
+	}
+	// References were checked when the schema was compiled
+	schema, _ = s.resolve(schema)
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+
+	violation := func(format string, args ...interface{}) ValidationError {
+		return ValidationError{Severity: SeverityError, Rule: RuleSchema, Path: path, Line: node.Line, Column: node.Column,
+			Message: fmt.Sprintf(format, args...)}
+	}
+
+	if schema.Type != "" && !nodeMatchesType(node, schema.Type) {
+		// Nothing below can be checked meaningfully if the type is wrong
+		return ValidationErrors{violation("expected %s, got %s", schema.Type, describeNode(node))}
+	}
+
+	var violations ValidationErrors
+
+	if len(schema.Enum) > 0 && node.Kind == yaml.ScalarNode && !containsString(schema.Enum, node.Value) {
+		violations = append(violations, violation("value %q is not one of [%s]", node.Value, strings.Join(schema.Enum, ", ")))
+	}
+
+	if schema.pattern != nil && node.Kind == yaml.ScalarNode && !schema.pattern.MatchString(node.Value) {
+		violations = append(violations, violation("value %q does not match pattern %s", node.Value, schema.Pattern))
+	}
+
+	if schema.Minimum != nil && node.Kind == yaml.ScalarNode {
+		if value, err := strconv.ParseFloat(node.Value, 64); err == nil && value < *schema.Minimum {
+			violations = append(violations, violation("value %v is less than minimum %v", value, *schema.Minimum))
+		}
+	}
+
+	if node.Kind == yaml.SequenceNode {
+		if schema.MinItems != nil && len(node.Content) < *schema.MinItems {
+			violations = append(violations, violation("expected at least %d item(s), got %d", *schema.MinItems, len(node.Content)))
+		}
+		if schema.Items != nil {
+			for i, item := range node.Content {
+				violations = append(violations, s.validate(schema.Items, item, pathIndex(path, i))...)
+			}
+		}
+	}
+
+	if node.Kind == yaml.MappingNode {
+		for _, name := range schema.Required {
+			if mappingValue(node, name) == nil {
+				violations = append(violations, violation("missing required property %q", name))
+			}
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			if prop, ok := schema.Properties[key]; ok {
+				violations = append(violations, s.validate(prop, value, pathKey(path, key))...)
+			} else if schema.AdditionalProperties != nil {
+				violations = append(violations, s.validate(schema.AdditionalProperties, value, pathKey(path, key))...)
+			}
+		}
+	}
+
+	for _, sub := range schema.AllOf {
+		violations = append(violations, s.validate(sub, node, path)...)
+	}
+
+	if len(schema.AnyOf) > 0 && s.countMatches(schema.AnyOf, node, path) == 0 {
+		violations = append(violations, violation("value does not match any of the allowed alternatives"))
+	}
+
+	if len(schema.OneOf) > 0 {
+		if matches := s.countMatches(schema.OneOf, node, path); matches != 1 {
+			violations = append(violations, violation("value must match exactly one of: %s (matched %d)",
+				alternativeTitles(schema.OneOf), matches))
+		}
+	}
+
+	if schema.Not != nil && len(s.validate(schema.Not, node, path)) == 0 {
+		violations = append(violations, violation("value matches a disallowed schema"))
+	}
+
+	return violations
+}
+
+// countMatches returns how many of the alternative schemas the node satisfies
+func (s *OpenAPISchema) countMatches(alternatives []*Schema, node *yaml.Node, path string) int {
+	matches := 0
+	for _, alt := range alternatives {
+		if len(s.validate(alt, node, path)) == 0 {
+			matches++
+		}
+	}
+	return matches
+}
+
+// nodeMatchesType reports whether a YAML node can hold a value of the given OpenAPI type.
+// Strings accept any non-null scalar, as unquoted numbers (e.g. decimal clock IDs) decode fine into strings.
+func nodeMatchesType(node *yaml.Node, schemaType string) bool {
+	switch schemaType {
+	case "object":
+		return node.Kind == yaml.MappingNode
+	case "array":
+		return node.Kind == yaml.SequenceNode
+	case "string":
+		return node.Kind == yaml.ScalarNode && node.ShortTag() != "!!null"
+	case "number":
+		return node.Kind == yaml.ScalarNode && (node.ShortTag() == "!!int" || node.ShortTag() == "!!float")
+	case "integer":
+		return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!int"
+	case "boolean":
+		return node.Kind == yaml.ScalarNode && node.ShortTag() == "!!bool"
+	}
+	return true
+}
+
+// describeNode returns a short human-readable kind for a YAML node
+func describeNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "object"
+	case yaml.SequenceNode:
+		return "array"
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			return "null"
+		case "!!int", "!!float":
+			return "number " + node.Value
+		case "!!bool":
+			return "boolean " + node.Value
+		}
+		return fmt.Sprintf("string %q", node.Value)
+	}
+	return "unknown"
+}
+
+// mappingValue returns the value node for a key in a mapping node, or nil if the key is absent
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// alternativeTitles lists the titles of oneOf alternatives for error messages
+func alternativeTitles(alternatives []*Schema) string {
+	titles := make([]string, 0, len(alternatives))
+	for i, alt := range alternatives {
+		if alt.Title != "" {
+			titles = append(titles, fmt.Sprintf("%q", alt.Title))
+		} else {
+			titles = append(titles, fmt.Sprintf("alternative %d", i+1))
+		}
+	}
+	return strings.Join(titles, ", ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
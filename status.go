@@ -0,0 +1,256 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+// DeviceStatus is a DPLL device together with the subsystem whose clock ID it matches
+type DeviceStatus struct {
+	DPLLDevice
+
+	// Subsystem is the name of the matching subsystem, empty if the device is not configured
+	Subsystem string `json:"subsystem,omitempty"`
+}
+
+// PinDrift is a pin setting that differs between the hardware and the configuration
+type PinDrift struct {
+	ClockID    ClockID  `json:"clockId"`
+	BoardLabel string   `json:"boardLabel"`
+	DPLLType   DPLLType `json:"dpllType,omitempty"`
+
+	// Setting is "prio", "state", "frequency", "phase-adjust" or "esync-frequency", or "pin" if the
+	// pin or its relation to the DPLL cannot be found
+	Setting  string `json:"setting"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+func (d PinDrift) String() string {
+	s := d.ClockID.String() + ":" + d.BoardLabel
+	if d.DPLLType != "" {
+		s += " " + string(d.DPLLType)
+	}
+	return fmt.Sprintf("%s %s: expected %s, actual %s", s, d.Setting, d.Expected, d.Actual)
+}
+
+// DPLLStatus is the live state of the DPLL devices and the drift of the pins from the state
+// expected for a condition
+type DPLLStatus struct {
+	// Condition is the name of the condition the hardware is expected to be in
+	Condition string         `json:"condition"`
+	Devices   []DeviceStatus `json:"devices"`
+	Drift     []PinDrift     `json:"drift"`
+}
+
+// CheckDrift reads the devices and pins of a backend and compares them with the pin configurations
+// of a merged clock chain and the pin states expected for a condition, as given by ConditionPinTable.
+// If conditionName is empty, the default conditions alone are expected.
+// Devices and pins are matched to the configuration by clock ID and board label.
+func CheckDrift(backend DPLLBackend, cc *ClockChain, conditionName string) (*DPLLStatus, error) {
+	condition, _, err := findPlanCondition(cc, conditionName)
+	if err != nil {
+		return nil, err
+	}
+	table := cc.ConditionPinTable(condition)
+	ops, err := PinConfigOperations(cc)
+	if err != nil {
+		return nil, err
+	}
+	stateOps, err := TableOperations(table)
+	if err != nil {
+		return nil, err
+	}
+	ops = append(ops, stateOps...)
+
+	devices, err := backend.Devices()
+	if err != nil {
+		return nil, fmt.Errorf("listing DPLL devices: %w", err)
+	}
+	pins, err := backend.Pins()
+	if err != nil {
+		return nil, fmt.Errorf("listing DPLL pins: %w", err)
+	}
+
+	status := &DPLLStatus{Condition: condition.Name, Drift: []PinDrift{}}
+	subsystems := make(map[ClockID]string)
+	for _, subsystem := range cc.Structure {
+		if subsystem.DPLL.ClockID != 0 {
+			subsystems[subsystem.DPLL.ClockID] = subsystem.Name
+		}
+	}
+	for _, device := range devices {
+		status.Devices = append(status.Devices, DeviceStatus{DPLLDevice: device, Subsystem: subsystems[device.ClockID]})
+	}
+
+	pinsByID := make(map[uint32]*DPLLPin, len(pins))
+	for i := range pins {
+		pinsByID[pins[i].ID] = &pins[i]
+	}
+	missing := make(map[string]bool)
+	for _, op := range ops {
+		drift := PinDrift{ClockID: op.ClockID, BoardLabel: op.BoardLabel, DPLLType: op.DPLLType}
+		report := func(setting, expected, actual string) {
+			drift.Setting, drift.Expected, drift.Actual = setting, expected, actual
+			status.Drift = append(status.Drift, drift)
+		}
+
+		request, err := resolvePinOperation(op, devices, pins)
+		if err != nil {
+			if !missing[err.Error()] {
+				missing[err.Error()] = true
+				report("pin", "present", err.Error())
+			}
+			continue
+		}
+		pin := pinsByID[request.PinID]
+		if op.Frequency != nil && *op.Frequency != pin.Frequency {
+			report("frequency", fmt.Sprint(*op.Frequency), fmt.Sprint(pin.Frequency))
+		}
+		if op.PhaseAdjust != nil && *op.PhaseAdjust != pin.PhaseAdjust {
+			report("phase-adjust", fmt.Sprint(*op.PhaseAdjust), fmt.Sprint(pin.PhaseAdjust))
+		}
+		if op.ESyncFrequency != nil && *op.ESyncFrequency != pin.ESyncFrequency {
+			report("esync-frequency", fmt.Sprint(*op.ESyncFrequency), fmt.Sprint(pin.ESyncFrequency))
+		}
+		if request.ParentDevice == nil {
+			continue
+		}
+
+		var parent *DPLLPinParent
+		for i := range pin.Parents {
+			if pin.Parents[i].DeviceID == *request.ParentDevice {
+				parent = &pin.Parents[i]
+			}
+		}
+		if parent == nil {
+			report("pin", "connected to DPLL device", fmt.Sprintf("not connected to DPLL device %d", *request.ParentDevice))
+			continue
+		}
+		if op.Priority != nil && (parent.Priority == nil || *parent.Priority != *op.Priority) {
+			actual := "-"
+			if parent.Priority != nil {
+				actual = fmt.Sprint(*parent.Priority)
+			}
+			report("prio", fmt.Sprint(*op.Priority), actual)
+		}
+		if op.State != "" && op.State != parent.State {
+			report("state", op.State, parent.State)
+		}
+	}
+	return status, nil
+}
+
+// Format renders the status as a table or as JSON
+func (s *DPLLStatus) Format(format PlanFormat) (string, error) {
+	switch format {
+	case PlanFormatTable, "":
+		return s.table(), nil
+	case PlanFormatJSON:
+		data, err := json.MarshalIndent(s, "", "  ")
+		if err != nil {
+			return "", err
+		}
+		return string(data) + "\n", nil
+	}
+	return "", fmt.Errorf("unknown status format %q (supported: %s, %s)", format, PlanFormatTable, PlanFormatJSON)
+}
+
+// table renders the status as human readable tables
+func (s *DPLLStatus) table() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "DPLL devices (%d):\n\n", len(s.Devices))
+	w := tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCLOCK ID\tTYPE\tSUBSYSTEM\tLOCK STATUS")
+	for _, device := range s.Devices {
+		subsystem := device.Subsystem
+		if subsystem == "" {
+			subsystem = "-"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", device.ID, device.ClockID, device.Type, subsystem, device.LockStatus)
+	}
+	w.Flush()
+
+	if len(s.Drift) == 0 {
+		fmt.Fprintf(&sb, "\nNo drift from condition %q\n", s.Condition)
+		return sb.String()
+	}
+	fmt.Fprintf(&sb, "\n%d setting(s) drifted from condition %q:\n\n", len(s.Drift), s.Condition)
+	w = tabwriter.NewWriter(&sb, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CLOCK ID\tBOARD LABEL\tDPLL\tSETTING\tEXPECTED\tACTUAL")
+	for _, drift := range s.Drift {
+		dpllType := string(drift.DPLLType)
+		if dpllType == "" {
+			dpllType = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", drift.ClockID, drift.BoardLabel, dpllType, drift.Setting, drift.Expected, drift.Actual)
+	}
+	w.Flush()
+	return sb.String()
+}
+
+// runStatus implements the status subcommand. It exits with 1 if any setting drifted.
+func runStatus(args []string) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	condition := flags.String("condition", "", "condition the hardware is expected to be in (default: the default condition)")
+	fixture := flags.String("fixture", "", "read devices and pins from a recorded fixture instead of netlink")
+	record := flags.String("record", "", "record the devices and pins to a fixture file")
+	format := flags.String("format", string(PlanFormatTable), "output format: table or json")
+	report := addReportFlags(flags)
+	addLoadFlags(flags, true)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: ptp-config-parser status [flags] <config-file>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return 2
+	}
+
+	loaded := loadForCommand(flags.Arg(0), report)
+	if loaded == nil {
+		return 1
+	}
+
+	var backend DPLLBackend
+	var err error
+	if *fixture != "" {
+		backend, err = LoadDPLLFixture(*fixture)
+	} else {
+		backend, err = NewNetlinkDPLL()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer backend.Close()
+
+	if *record != "" {
+		if err := RecordDPLLFixture(backend, *record); err != nil {
+			fmt.Fprintf(os.Stderr, "Error recording fixture: %v\n", err)
+			return 1
+		}
+	}
+	status, err := CheckDrift(backend, loaded.Config, *condition)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	rendered, err := status.Format(PlanFormat(*format))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	fmt.Print(rendered)
+	if len(status.Drift) > 0 {
+		return 1
+	}
+	return 0
+}
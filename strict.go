@@ -0,0 +1,136 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExtensionKeyPrefix marks mapping keys reserved for extensions. Such keys are accepted anywhere a
+// field is expected and ignored, so that documents can carry data for newer or third-party tools.
+const ExtensionKeyPrefix = "x-"
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// clockChainType decodes itself only to resolve clock aliases, so its fields are still checked
+var clockChainType = reflect.TypeOf(ClockChain{})
+
+// decodeStrict decodes a YAML node into out, after checking that every mapping key names a field of
+// the target type. Unknown keys are reported together, with their position and the closest valid
+// field name, instead of being silently dropped.
+func decodeStrict(node *yaml.Node, out interface{}) error {
+	var vc validationCollector
+	checkKnownFields(&vc, node, reflect.TypeOf(out), "")
+	if err := vc.result(); err != nil {
+		vc.errs.Sort()
+		return vc.errs
+	}
+	return node.Decode(out)
+}
+
+// checkKnownFields records a finding for every mapping key under node that does not match a field of
+// type t. Types that decode themselves and map keys are not checked.
+func checkKnownFields(vc *validationCollector, node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			checkKnownFields(vc, child, t, path)
+		}
+		return
+	case yaml.AliasNode:
+		checkKnownFields(vc, node.Alias, t, path)
+		return
+	}
+	if t != clockChainType && reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				checkMergedFields(vc, value, t, path)
+				continue
+			}
+			if strings.HasPrefix(key.Value, ExtensionKeyPrefix) {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				names := make([]string, 0, len(fields))
+				for name := range fields {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+				vc.add(RuleUnknownField, pathKey(path, key.Value), "unknown field %q%s, valid fields: %s",
+					key.Value, didYouMean(key.Value, names), strings.Join(names, ", "))
+				last := &vc.errs[len(vc.errs)-1]
+				last.Line, last.Column = key.Line, key.Column
+				continue
+			}
+			checkKnownFields(vc, value, field, pathKey(path, key.Value))
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkKnownFields(vc, node.Content[i+1], t.Elem(), pathKey(path, node.Content[i].Value))
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkKnownFields(vc, item, t.Elem(), pathIndex(path, i))
+		}
+	}
+}
+
+// checkMergedFields checks the mapping, or sequence of mappings, merged into a struct by a "<<" key
+func checkMergedFields(vc *validationCollector, node *yaml.Node, t reflect.Type, path string) {
+	if node.Kind == yaml.SequenceNode {
+		for _, item := range node.Content {
+			checkKnownFields(vc, item, t, path)
+		}
+		return
+	}
+	checkKnownFields(vc, node, t, path)
+}
+
+// yamlFields returns the types of the fields of a struct by their YAML key, following the naming
+// rules of yaml.v3: the name in the yaml tag, or the lowercased field name, with inline structs flattened
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name, options, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(options, "inline") && field.Type.Kind() == reflect.Struct {
+			for inlineName, inlineType := range yamlFields(field.Type) {
+				fields[inlineName] = inlineType
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
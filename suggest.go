@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// suggest returns the candidate closest to value by edit distance, ignoring case, or "" if no
+// candidate is close enough to be a likely typo. Candidates that contain value, such as
+// "esyncConfigName" for "esyncConfig", are likely truncations and always close enough.
+func suggest(value string, candidates []string) string {
+	limit := len(value) / 3
+	if limit < 2 {
+		limit = 2
+	}
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		lowerValue, lowerCandidate := strings.ToLower(value), strings.ToLower(candidate)
+		distance := editDistance(lowerValue, lowerCandidate)
+		if distance > limit && (len(value) < 3 || !strings.Contains(lowerCandidate, lowerValue)) {
+			continue
+		}
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// didYouMean formats a " (did you mean X?)" hint for an unknown value, or "" if no candidate is close
+func didYouMean(value string, candidates []string) string {
+	if suggestion := suggest(value, candidates); suggestion != "" {
+		return fmt.Sprintf(" (did you mean %q?)", suggestion)
+	}
+	return ""
+}
+
+// editDistance returns the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IgnoreDirective starts a YAML comment suppressing rules on the commented node and everything
+// below it, e.g. "# lint:ignore dangling-output, pin-description -- reserved for lab equipment".
+// Text after "--" is a free-form reason.
+const IgnoreDirective = "lint:ignore"
+
+// LintConfig configures the reporting of validation findings
+type LintConfig struct {
+	// Suppress lists findings that are accepted and not reported
+	Suppress []LintSuppression `yaml:"suppress,omitempty"`
+}
+
+// LintSuppression suppresses the findings of a rule, optionally only at a path and below it
+type LintSuppression struct {
+	// Rule is the ID of the suppressed rule
+	Rule string `yaml:"rule"`
+
+	// Path restricts the suppression to findings at this path or below it, e.g. structure[0].dpll.
+	// If omitted, the rule is suppressed everywhere.
+	Path string `yaml:"path,omitempty"`
+
+	// Reason documents why the findings are accepted
+	Reason string `yaml:"reason,omitempty"`
+}
+
+// Suppress splits findings into the reported ones and the ones suppressed by the lint section or by
+// lint:ignore comments on the offending node or its ancestors in the source document
+func (errs ValidationErrors) Suppress(root *yaml.Node, lint *LintConfig) (kept, suppressed ValidationErrors) {
+	for _, finding := range errs {
+		if lint.suppresses(finding) || commentSuppresses(root, finding) {
+			suppressed = append(suppressed, finding)
+		} else {
+			kept = append(kept, finding)
+		}
+	}
+	return kept, suppressed
+}
+
+// suppresses reports whether a suppression of the lint section matches a finding
+func (lc *LintConfig) suppresses(finding ValidationError) bool {
+	if lc == nil {
+		return false
+	}
+	for _, suppression := range lc.Suppress {
+		if suppression.Rule == finding.Rule && pathWithin(finding.Path, suppression.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathWithin reports whether a path is equal to or below a parent path. Every path is within the empty path.
+func pathWithin(path, parent string) bool {
+	if parent == "" || path == parent {
+		return true
+	}
+	return strings.HasPrefix(path, parent) && (path[len(parent)] == '.' || path[len(parent)] == '[')
+}
+
+// commentSuppresses reports whether a lint:ignore comment on the node at the path of a finding, or on
+// one of its ancestors or their keys, names the rule of the finding
+func commentSuppresses(root *yaml.Node, finding ValidationError) bool {
+	if root == nil {
+		return false
+	}
+	for _, node := range nodesAlongPath(root, finding.Path) {
+		for _, comment := range []string{node.HeadComment, node.LineComment} {
+			for _, rule := range ignoredRules(comment) {
+				if rule == finding.Rule {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// nodesAlongPath returns the nodes from the document root to the node at a path, including the
+// mapping keys, as far as the path can be followed
+func nodesAlongPath(root *yaml.Node, path string) []*yaml.Node {
+	nodes := []*yaml.Node{root}
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nodes
+		}
+		node = node.Content[0]
+		nodes = append(nodes, node)
+	}
+
+	for _, elem := range splitPath(path) {
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; !elem.isIndex && i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == elem.key {
+					nodes = append(nodes, node.Content[i])
+					next = node.Content[i+1]
+					break
+				}
+			}
+		case yaml.SequenceNode:
+			if elem.isIndex && elem.index < len(node.Content) {
+				next = node.Content[elem.index]
+			}
+		}
+		if next == nil {
+			return nodes
+		}
+		nodes = append(nodes, next)
+		node = next
+	}
+	return nodes
+}
+
+// ignoredRules returns the rule IDs named by the lint:ignore directives of a comment
+func ignoredRules(comment string) []string {
+	var rules []string
+	for _, line := range strings.Split(comment, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if !strings.HasPrefix(line, IgnoreDirective) {
+			continue
+		}
+		line, _, _ = strings.Cut(strings.TrimPrefix(line, IgnoreDirective), "--")
+		rules = append(rules, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		})...)
+	}
+	return rules
+}
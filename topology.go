@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// TopologyLink is a link resolved to the pins it connects
+type TopologyLink struct {
+	// Index is the index of the link in ClockChain.Links, or -1 if the link was inferred from connectors
+	Index int
+
+	// Name and Description are copied from the link definition, or derived from the pins for inferred links
+	Name        string
+	Description string
+
+	// From and To are the linked pins, or nil for an external end
+	From *PinInfo
+	To   *PinInfo
+
+	// FromExternal and ToExternal describe the external equipment at an end that is not a pin
+	FromExternal string
+	ToExternal   string
+}
+
+// Inferred reports whether the link was inferred from matching connector names
+func (l *TopologyLink) Inferred() bool {
+	return l.Index < 0
+}
+
+// Internal reports whether both ends of the link are pins of the clock chain
+func (l *TopologyLink) Internal() bool {
+	return l.From != nil && l.To != nil
+}
+
+// path returns the location used to report findings about the link
+func (l *TopologyLink) path() string {
+	if !l.Inferred() {
+		return pathIndex("links", l.Index)
+	}
+	return l.From.path()
+}
+
+func (l *TopologyLink) String() string {
+	from, to := l.FromExternal, l.ToExternal
+	if l.From != nil {
+		from = l.From.String()
+	}
+	if l.To != nil {
+		to = l.To.String()
+	}
+	return from + " -> " + to
+}
+
+// path returns the location of the pin declaration, or of the subsystem for plugin-only pins
+func (p *PinInfo) path() string {
+	subsystemPath := pathIndex("structure", p.SubsystemIndex)
+	if p.Group == "" {
+		return subsystemPath
+	}
+	return pathKey(subsystemPath+".dpll."+p.Group, p.BoardLabel)
+}
+
+func (p *PinInfo) String() string {
+	return p.Subsystem.Name + "/" + p.BoardLabel
+}
+
+// Key returns the clock ID and board label of the pin
+func (p *PinInfo) Key() PinKey {
+	return NewPinKey(p.Subsystem.DPLL.ClockID, p.BoardLabel)
+}
+
+// Topology is the graph of subsystems, pins and the links between them
+type Topology struct {
+	Links []TopologyLink
+}
+
+// BuildTopology resolves the links of a clock chain against its pins. If the configuration has no
+// links section, links are inferred between an output and an input pin in different subsystems that
+// are routed to connectors of the same name, provided neither pin matches any other pin.
+// Problems resolving links are returned as validation findings.
+func BuildTopology(cc *ClockChain, pins *PinIndex) (*Topology, ValidationErrors) {
+	var vc validationCollector
+	topology := &Topology{}
+
+	if len(cc.Links) == 0 {
+		topology.inferLinks(cc, pins, &vc)
+		return topology, vc.errs
+	}
+
+	for li, link := range cc.Links {
+		linkPath := pathIndex("links", li)
+		resolved := TopologyLink{Index: li, Name: link.Name, Description: link.Description}
+		ok := true
+		for _, end := range []struct {
+			name      string
+			end       LinkEnd
+			pin       **PinInfo
+			external  *string
+			direction PinDirection
+		}{
+			{"from", link.From, &resolved.From, &resolved.FromExternal, PinDirectionOutput},
+			{"to", link.To, &resolved.To, &resolved.ToExternal, PinDirectionInput},
+		} {
+			endPath := linkPath + "." + end.name
+			if end.end.External != "" {
+				if end.end.ClockID != 0 || end.end.BoardLabel != "" {
+					vc.add(RuleLinkEndpoint, endPath, "external is mutually exclusive with clockId and boardLabel")
+					ok = false
+				}
+				*end.external = end.end.External
+				continue
+			}
+			pin := resolvePinReference(&vc, pins, endPath, end.end.ClockID, end.end.BoardLabel)
+			if pin == nil {
+				ok = false
+				continue
+			}
+			if pin.Direction != PinDirectionUnknown && pin.Direction != end.direction {
+				vc.add(RuleLinkDirection, endPath, "link %s pin %s must be an %s, but it is an %s",
+					end.name, pin, end.direction, pin.Direction)
+				ok = false
+			}
+			*end.pin = pin
+		}
+		if ok && resolved.From == nil && resolved.To == nil {
+			vc.add(RuleLinkEndpoint, linkPath, "at least one end of a link must be a pin of the clock chain")
+			ok = false
+		}
+		if ok {
+			topology.Links = append(topology.Links, resolved)
+		}
+	}
+
+	return topology, vc.errs
+}
+
+// inferLinks links output and input pins in different subsystems routed to connectors of the same name
+func (t *Topology) inferLinks(cc *ClockChain, pins *PinIndex, vc *validationCollector) {
+	outputs := make(map[string][]*PinInfo)
+	inputs := make(map[string][]*PinInfo)
+	var connectors []string
+	for _, pin := range declaredPins(cc, pins) {
+		connector := pin.Config.Connector
+		if connector == "" {
+			continue
+		}
+		if len(outputs[connector]) == 0 && len(inputs[connector]) == 0 {
+			connectors = append(connectors, connector)
+		}
+		if pin.Direction == PinDirectionOutput {
+			outputs[connector] = append(outputs[connector], pin)
+		} else {
+			inputs[connector] = append(inputs[connector], pin)
+		}
+	}
+
+	// otherSubsystems returns the pins that are not in the subsystem of the given pin
+	otherSubsystems := func(candidates []*PinInfo, pin *PinInfo) []*PinInfo {
+		var result []*PinInfo
+		for _, candidate := range candidates {
+			if candidate.SubsystemIndex != pin.SubsystemIndex {
+				result = append(result, candidate)
+			}
+		}
+		return result
+	}
+
+	for _, connector := range connectors {
+		for _, output := range outputs[connector] {
+			candidates := otherSubsystems(inputs[connector], output)
+			if len(candidates) == 0 {
+				continue
+			}
+			if len(candidates) > 1 || len(otherSubsystems(outputs[connector], candidates[0])) > 1 {
+				names := make([]string, len(candidates))
+				for i, candidate := range candidates {
+					names[i] = candidate.String()
+				}
+				vc.warn(RuleLinkInference, output.path(),
+					"output %s on connector %s could be linked to %s; declare the link in the links section",
+					output, connector, strings.Join(names, ", "))
+				continue
+			}
+			t.Links = append(t.Links, TopologyLink{
+				Index:       -1,
+				Name:        fmt.Sprintf("%s to %s", output, candidates[0]),
+				Description: fmt.Sprintf("inferred from connector %s", connector),
+				From:        output,
+				To:          candidates[0],
+			})
+		}
+	}
+}
+
+// declaredPins returns the pins declared in the DPLL pin maps, ordered by subsystem and board label
+func declaredPins(cc *ClockChain, pins *PinIndex) []*PinInfo {
+	var result []*PinInfo
+	for si := range cc.Structure {
+		clockID := cc.Structure[si].DPLL.ClockID
+		for _, label := range pins.Labels(clockID) {
+			pin, _ := pins.Lookup(clockID, label)
+			if pin.SubsystemIndex == si && pin.Declared() {
+				result = append(result, pin)
+			}
+		}
+	}
+	return result
+}
+
+// validate checks the topology for signal mismatches across links, cabled pins that are not
+// linked, and timing loops that are active in any condition
+func (t *Topology) validate(vc *validationCollector, cc *ClockChain, pins *PinIndex) {
+	esyncConfigs := make(map[string]ESyncConfig)
+	if cc.CommonDefinitions != nil {
+		for _, def := range cc.CommonDefinitions.ESyncDefinitions {
+			esyncConfigs[def.Name] = def.ESyncConfig
+		}
+	}
+
+	linkedOutputs := make(map[*PinInfo]bool)
+	linkedInputs := make(map[*PinInfo]bool)
+	for i := range t.Links {
+		link := &t.Links[i]
+		if link.From != nil {
+			linkedOutputs[link.From] = true
+		}
+		if link.To != nil {
+			linkedInputs[link.To] = true
+		}
+		if link.Internal() && link.From.Declared() && link.To.Declared() {
+			checkLinkSignal(vc, link, esyncConfigs)
+		}
+	}
+
+	sourcePins := make(map[PinKey]bool)
+	if cc.Behavior != nil {
+		for _, source := range cc.Behavior.Sources {
+			sourcePins[NewPinKey(source.ClockID, source.BoardLabel)] = true
+		}
+	}
+
+	for _, pin := range declaredPins(cc, pins) {
+		if pin.Config.Connector == "" {
+			continue
+		}
+		if pin.Direction == PinDirectionOutput && !linkedOutputs[pin] {
+			vc.warn(RuleDanglingOutput, pin.path(), "output %s on connector %s is not linked to any input",
+				pin, pin.Config.Connector)
+		}
+		if pin.Direction == PinDirectionInput && !linkedInputs[pin] && !sourcePins[pin.Key()] {
+			vc.warn(RuleUndrivenInput, pin.path(), "input %s on connector %s has no driving link and is not a source",
+				pin, pin.Config.Connector)
+		}
+	}
+
+	t.validateLoops(vc, cc)
+}
+
+// checkLinkSignal reports eSync and frequency mismatches between the two ends of a link
+func checkLinkSignal(vc *validationCollector, link *TopologyLink, esyncConfigs map[string]ESyncConfig) {
+	from, to := link.From.Config, link.To.Config
+	switch {
+	case (from.ESyncConfigName == "") != (to.ESyncConfigName == ""):
+		vc.add(RuleLinkSignal, link.path(), "eSync mismatch on link %s: output eSync %q, input eSync %q",
+			link, from.ESyncConfigName, to.ESyncConfigName)
+	case from.ESyncConfigName != "":
+		fromConfig, fromOK := esyncConfigs[from.ESyncConfigName]
+		toConfig, toOK := esyncConfigs[to.ESyncConfigName]
+		if fromOK && toOK && fromConfig.withDefaults() != toConfig.withDefaults() {
+			vc.add(RuleLinkSignal, link.path(), "eSync mismatch on link %s: output uses %s, input uses %s",
+				link, from.ESyncConfigName, to.ESyncConfigName)
+		}
+	default:
+		fromFrequency, fromOK := link.From.frequency()
+		toFrequency, toOK := link.To.frequency()
+		if fromOK && toOK && fromFrequency != toFrequency {
+			vc.add(RuleLinkSignal, link.path(), "frequency mismatch on link %s: output %s, input %s",
+				link, formatFrequency(fromFrequency), formatFrequency(toFrequency))
+		}
+	}
+}
+
+// withDefaults returns the eSync configuration with omitted values set to their documented defaults
+func (ec ESyncConfig) withDefaults() ESyncConfig {
+	if ec.EmbeddedSyncFrequency == 0 {
+		ec.EmbeddedSyncFrequency = 1
+	}
+	if ec.DutyCyclePct == 0 {
+		ec.DutyCyclePct = 25
+	}
+	return ec
+}
+
+// frequency returns the configured pin frequency. Phase pins without a frequency default to 1 PPS.
+func (p *PinInfo) frequency() (float64, bool) {
+	if p.Config == nil || p.Config.ESyncConfigName != "" {
+		return 0, false
+	}
+	if p.Config.Frequency != nil {
+		return *p.Config.Frequency, true
+	}
+	if p.Kind == PinKindPhase {
+		return 1, true
+	}
+	return 0, false
+}
+
+// validateLoops reports timing loops: cycles of links whose output and input pins are both enabled.
+// Pin states are evaluated for the default conditions alone and for the state of each other
+// condition, as given by ConditionPinTable.
+func (t *Topology) validateLoops(vc *validationCollector, cc *ClockChain) {
+	type state struct {
+		name  string
+		table PinTable
+	}
+	states := []state{{"default", cc.DefaultPinTable()}}
+	if cc.Behavior != nil {
+		for i := range cc.Behavior.Conditions {
+			condition := &cc.Behavior.Conditions[i]
+			if isDefaultCondition(condition) {
+				continue
+			}
+			states = append(states, state{condition.Name, cc.ConditionPinTable(condition)})
+		}
+	}
+
+	var order []string
+	loops := make(map[string][]*TopologyLink)
+	activeIn := make(map[string][]string)
+	for _, st := range states {
+		for _, cycle := range t.Cycles(st.table.LinkActive) {
+			signature := cycleSignature(cycle)
+			if _, seen := loops[signature]; !seen {
+				order = append(order, signature)
+				loops[signature] = cycle
+			}
+			activeIn[signature] = append(activeIn[signature], fmt.Sprintf("%q", st.name))
+		}
+	}
+
+	for _, signature := range order {
+		cycle := loops[signature]
+		hops := make([]string, len(cycle))
+		for i, link := range cycle {
+			hops[i] = link.String()
+		}
+		vc.add(RuleTopologyLoop, cycle[0].path(), "timing loop %s is active in: %s",
+			strings.Join(hops, ", "), strings.Join(activeIn[signature], ", "))
+	}
+}
+
+// LinkActive reports whether a signal can flow over an internal link, i.e. both its output and its
+// input pin are enabled in the table
+func (t PinTable) LinkActive(link *TopologyLink) bool {
+	return link.Internal() && t.OutputEnabled(link.From.Key()) && t.InputEnabled(link.To.Key())
+}
+
+// Cycles returns the elementary cycles formed by the internal links for which active returns true.
+// Each cycle is returned once, starting at its subsystem with the lowest index.
+func (t *Topology) Cycles(active func(*TopologyLink) bool) [][]*TopologyLink {
+	adjacency := make(map[int][]*TopologyLink)
+	var nodes []int
+	for i := range t.Links {
+		link := &t.Links[i]
+		if !active(link) {
+			continue
+		}
+		from := link.From.SubsystemIndex
+		if len(adjacency[from]) == 0 {
+			nodes = append(nodes, from)
+		}
+		adjacency[from] = append(adjacency[from], link)
+	}
+	sort.Ints(nodes)
+
+	var cycles [][]*TopologyLink
+	for _, start := range nodes {
+		var path []*TopologyLink
+		visited := make(map[int]bool)
+		var walk func(node int)
+		walk = func(node int) {
+			for _, link := range adjacency[node] {
+				next := link.To.SubsystemIndex
+				if next == start {
+					cycle := make([]*TopologyLink, len(path)+1)
+					copy(cycle, path)
+					cycle[len(path)] = link
+					cycles = append(cycles, cycle)
+					continue
+				}
+				if next < start || visited[next] {
+					continue
+				}
+				visited[next] = true
+				path = append(path, link)
+				walk(next)
+				path = path[:len(path)-1]
+				visited[next] = false
+			}
+		}
+		walk(start)
+	}
+	return cycles
+}
+
+// cycleSignature identifies a cycle by the links it consists of
+func cycleSignature(cycle []*TopologyLink) string {
+	parts := make([]string, len(cycle))
+	for i, link := range cycle {
+		parts[i] = link.String()
+	}
+	return strings.Join(parts, "|")
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TransitionPhase orders the operations of a transition between conditions
+type TransitionPhase string
+
+const (
+	// TransitionBreak operations disconnect or deprioritize pins. They are applied first.
+	TransitionBreak TransitionPhase = "break"
+
+	// TransitionMake operations connect or prioritize pins. They are applied after all break operations.
+	TransitionMake TransitionPhase = "make"
+)
+
+// BuildTransitionPlan plans the minimal pin changes that move the hardware from the state of one
+// condition to the state of another. The state of a condition is given by ConditionPinTable; the
+// target state is the state of the first condition with the second one applied on top, as the engine
+// applies it.
+//
+// Operations that disconnect or deprioritize pins are ordered before operations that connect or
+// prioritize them, so that no signal path is opened before the paths it replaces are closed. Steps
+// after which a timing loop is active are flagged with the loops, using the link topology.
+func BuildTransitionPlan(cc *ClockChain, fromName, toName string) (*Plan, error) {
+	from, _, err := findPlanCondition(cc, fromName)
+	if err != nil {
+		return nil, err
+	}
+	to, ti, err := findPlanCondition(cc, toName)
+	if err != nil {
+		return nil, err
+	}
+
+	before := cc.ConditionPinTable(from)
+	after := before.Clone()
+	after.Apply(to.DesiredStates)
+
+	var breaks, makes []PlanStep
+	for _, change := range before.Diff(after) {
+		steps, err := transitionSteps(change, to, cc.conditionPath(ti))
+		if err != nil {
+			return nil, fmt.Errorf("condition %q: %w", to.Name, err)
+		}
+		for _, step := range steps {
+			if step.Phase == TransitionBreak {
+				breaks = append(breaks, step)
+			} else {
+				makes = append(makes, step)
+			}
+		}
+	}
+
+	plan := &Plan{From: from.Name, Condition: to.Name, Steps: append(breaks, makes...)}
+	topology, _ := BuildTopology(cc, NewPinIndex(cc, nil))
+	table := before.Clone()
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		table.Apply([]DesiredState{step.desiredState()})
+		for _, cycle := range topology.Cycles(table.LinkActive) {
+			hops := make([]string, len(cycle))
+			for j, link := range cycle {
+				hops[j] = link.String()
+			}
+			step.Loops = append(step.Loops, strings.Join(hops, ", "))
+		}
+	}
+	return plan, nil
+}
+
+// transitionSteps splits a pin change into a priority and a state operation and assigns each one
+// to its phase
+func transitionSteps(change PinChange, to *Condition, conditionPath string) ([]PlanStep, error) {
+	op := PinOperation{ClockID: change.Key.ClockID, BoardLabel: change.Key.BoardLabel, DPLLType: DPLLType(change.DPLL)}
+	origin := transitionOrigin(change, to, conditionPath)
+
+	var steps []PlanStep
+	if after := change.After.Priority; after != nil && (change.Before.Priority == nil || *change.Before.Priority != *after) {
+		if *after < 0 || *after > math.MaxUint32 || *after != math.Trunc(*after) {
+			return nil, fmt.Errorf("%s: invalid %s priority %g", change.Key, change.DPLL, *after)
+		}
+		priority := uint32(*after)
+		phase := TransitionMake
+		if before := change.Before.Priority; *after >= DisabledPriority || (before != nil && *after > *before) {
+			phase = TransitionBreak
+		}
+		step := PlanStep{PinOperation: op, Origin: origin, Phase: phase}
+		step.Priority = &priority
+		steps = append(steps, step)
+	}
+	if change.After.State != "" && change.After.State != change.Before.State {
+		phase := TransitionMake
+		if change.After.State == PinStateDisconnected {
+			phase = TransitionBreak
+		}
+		step := PlanStep{PinOperation: op, Origin: origin, Phase: phase}
+		step.State = string(change.After.State)
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// transitionOrigin returns the path of the last desired state of the condition that sets the
+// changed pin in the changed DPLL
+func transitionOrigin(change PinChange, to *Condition, conditionPath string) string {
+	origin := ""
+	for di, ds := range to.DesiredStates {
+		if NewPinKey(ds.ClockID, ds.BoardLabel) != change.Key {
+			continue
+		}
+		if (change.DPLL == string(DPLLTypeEEC) && ds.EEC != nil) || (change.DPLL == string(DPLLTypePPS) && ds.PPS != nil) {
+			origin = pathIndex(conditionPath+".desiredStates", di) + "." + change.DPLL
+		}
+	}
+	return origin
+}
+
+// desiredState converts the priority and state of a plan step back into a desired state
+func (step PlanStep) desiredState() DesiredState {
+	ds := DesiredState{ClockID: step.ClockID, BoardLabel: step.BoardLabel}
+	state := &PinState{State: PinStateValue(step.State)}
+	if step.Priority != nil {
+		priority := float64(*step.Priority)
+		state.Priority = &priority
+	}
+	switch step.DPLLType {
+	case DPLLTypeEEC:
+		ds.EEC = state
+	case DPLLTypePPS:
+		ds.PPS = state
+	}
+	return ds
+}
+
+// Unsafe reports whether a timing loop is active after any step of the plan
+func (p *Plan) Unsafe() bool {
+	for _, step := range p.Steps {
+		if len(step.Loops) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,12 @@ package main
 
 import (
 	"fmt"
+	"net"
 	"regexp"
+	"sort"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ClockChain represents the root configuration structure for clock chain configuration.
@@ -19,9 +23,14 @@ type ClockChain struct {
 	// Must contain at least one subsystem.
 	Structure []Subsystem `yaml:"structure"`
 
+	// Links describe the cables connecting output pins of one subsystem to input pins of another.
+	// If omitted, links are inferred from matching connector names where this is unambiguous.
+	Links []Link `yaml:"links,omitempty"`
+
 	// Behavior defines the system behavior based on synchronization sources, conditions and
-	// associated actions. The conditions for the sources can be "default", "locked" or "lost".
-	// The "default" condition initializes the hardware in each subsystem to allow the "Acquiring" state.
+	// associated actions. The conditions for the sources can be "default", "init", "locked" or "lost".
+	// The "default" condition initializes the hardware in each subsystem to the manufacturer recommended
+	// defaults. The "init" condition is applied after it, to allow the "Acquiring" state.
 	// Bidirectional links between different subsystems can remain disconnected, as the desired link
 	// direction is still unknown. The "locked" condition in one of the subsystems will configure
 	// the bidirectional links to be disciplined by the locked subsystem. If more than one subsystem
@@ -30,6 +39,9 @@ type ClockChain struct {
 	// holdover (subject to the daemon holdover decision). Other subsystems will be connected to
 	// follow the DPLL in holdover.
 	Behavior *Behavior `yaml:"behavior,omitempty"`
+
+	// Lint suppresses validation findings that are accepted for this configuration
+	Lint *LintConfig `yaml:"lint,omitempty"`
 }
 
 // CommonDefinitions contains shared definitions used across the configuration.
@@ -42,15 +54,43 @@ type CommonDefinitions struct {
 	ESyncDefinitions []ESyncDefinition `yaml:"eSyncDefinitions,omitempty"`
 
 	// RefSyncDefinitions is an array of named reference sync configurations that can be
-	// referenced by name from pin configurations throughout the system.
-	// A ref-sync configuration typically ties a reference sync definition to a specific
-	// related pin or board label.
+	// referenced by name from pin configurations throughout the system (refSyncConfigName).
+	// A ref-sync configuration ties a frequency input to the related phase pin board label,
+	// resolved within the subsystem of the referencing pin.
 	RefSyncDefinitions []RefSyncDefinition `yaml:"refSyncDefinitions,omitempty"`
 
 	// ClockIdentifiers defines aliases for clock IDs to simplify configuration files
 	ClockIdentifiers []ClockIdentifier `yaml:"clockIdentifiers,omitempty"`
 }
 
+// Link describes a cable carrying a signal from an output pin to an input pin.
+// Either end can be external to the chain (e.g. a GNSS receiver or measurement equipment).
+type Link struct {
+	// Name is an optional human-readable link name
+	Name string `yaml:"name,omitempty"`
+
+	// From is the driving end of the link, usually an output pin
+	From LinkEnd `yaml:"from"`
+
+	// To is the receiving end of the link, usually an input pin
+	To LinkEnd `yaml:"to"`
+
+	// Description is an optional description for this link
+	Description string `yaml:"description,omitempty"`
+}
+
+// LinkEnd identifies one end of a link, either by clock ID and pin board label, or as external equipment
+type LinkEnd struct {
+	// ClockID is the subsystem clock ID (decimal, hex or alias)
+	ClockID ClockID `yaml:"clockId,omitempty"`
+
+	// BoardLabel and clock ID together unambiguously identify the DPLL pin at this end of the link
+	BoardLabel string `yaml:"boardLabel,omitempty"`
+
+	// External describes equipment outside the clock chain. Mutually exclusive with clockId and boardLabel.
+	External string `yaml:"external,omitempty"`
+}
+
 // ESyncDefinition defines a named eSync configuration that can be referenced by name from pin configurations.
 type ESyncDefinition struct {
 	// Name is a unique identifier for this eSync configuration
@@ -71,13 +111,22 @@ type RefSyncDefinition struct {
 	RelatedPinBoardLabel string `yaml:"relatedPinBoardLabel,omitempty"`
 }
 
-// ClockIdentifier defines a mapping between a human-friendly alias and a clock ID
+// ClockIdentifier defines a mapping between a human-friendly alias and a clock ID. Exactly one of
+// ClockID, MAC and Interface must be set.
 type ClockIdentifier struct {
 	// Alias is the short human-friendly identifier
 	Alias string `yaml:"alias"`
 
 	// ClockID is the actual clock ID (decimal or hex)
-	ClockID string `yaml:"clockId"`
+	ClockID ClockID `yaml:"clockId,omitempty"`
+
+	// MAC is a MAC address the clock ID is derived from as its EUI-64, e.g. "11:22:33:44:55:66"
+	// for clock ID 0x112233fffe445566
+	MAC string `yaml:"mac,omitempty"`
+
+	// Interface is a network interface whose MAC address, read from SysfsRoot, the clock ID is
+	// derived from as its EUI-64
+	Interface string `yaml:"interface,omitempty"`
 
 	// Description is optional context for the mapping
 	Description string `yaml:"description,omitempty"`
@@ -97,7 +146,7 @@ type ESyncConfig struct {
 }
 
 // Behavior defines the system behavior based on synchronization sources, conditions and associated actions.
-// The conditions for the sources can be "default", "locked" or "lost".
+// The conditions for the sources can be "default", "init", "locked" or "lost".
 type Behavior struct {
 	// Sources of frequency, phase and time reference. Sources are identified by clock ID and pin board label,
 	// tying them to the specific subsystem entity. Sources are characterized by type and can be referenced
@@ -116,12 +165,12 @@ type SourceConfig struct {
 	Name string `yaml:"name"`
 
 	// ClockID is the subsystem clock ID (decimal or hex format: "5799633565432596414" or "0xaabbccfffeddeeff")
-	ClockID string `yaml:"clockId"`
+	ClockID ClockID `yaml:"clockId"`
 
 	// SourceType identifies the source type. Valid values: "ptpTimeReceiver", "gnss"
 	// If sourceType is ptpTimeReceiver, ptpTimeReceivers must be specified.
 	// In all cases, boardLabel must be specified.
-	SourceType string `yaml:"sourceType"`
+	SourceType SourceType `yaml:"sourceType"`
 
 	// BoardLabel and clock ID together unambiguously identify the subsystem and the DPLL pin receiving the source
 	BoardLabel string `yaml:"boardLabel"`
@@ -135,6 +184,7 @@ type SourceConfig struct {
 // The first condition in the array is the Triggering Condition, while all others are Supporting Conditions
 // (that must be true for the desired states to be applied). For example, if two different subsystems have
 // two different sources, there is still only one subsystem that will activate holdover if all other sources are lost.
+// Supporting Conditions may be expressions combining source states with any, all and not.
 type Condition struct {
 	// Name is a human-readable condition name
 	Name string `yaml:"name"`
@@ -146,22 +196,127 @@ type Condition struct {
 	// DesiredStates is a list of pin and connector settings that together define the desired state.
 	// The configurations are applied (in the order they are listed) when the condition is triggered.
 	DesiredStates []DesiredState `yaml:"desiredStates"`
+
+	// generated marks conditions created from plugin defaults, which are not part of the source document
+	generated bool
 }
 
-// SourceState represents the state of a source in a condition evaluation.
+// SourceState represents the state of a source in a condition evaluation, or an expression over
+// source states. A source state sets SourceName and ConditionType, an expression exactly one of Any,
+// All and Not, e.g. "GNSS lost AND (PTP1 lost OR PTP2 lost)" is written as
+//
+//	sources:
+//	- sourceName: GNSS
+//	  conditionType: lost
+//	- any:
+//	  - sourceName: PTP1
+//	    conditionType: lost
+//	  - sourceName: PTP2
+//	    conditionType: lost
 type SourceState struct {
 	// SourceName is the name of the source being evaluated
-	SourceName string `yaml:"sourceName"`
+	SourceName string `yaml:"sourceName,omitempty"`
 
 	// ConditionType is the state condition of the source.
-	// Valid values: "default", "locked", "lost"
-	ConditionType string `yaml:"conditionType"`
+	// Valid values: "default", "init", "locked", "lost"
+	ConditionType ConditionType `yaml:"conditionType,omitempty"`
+
+	// Any is true if at least one of its source states is true
+	Any []SourceState `yaml:"any,omitempty"`
+
+	// All is true if all of its source states are true
+	All []SourceState `yaml:"all,omitempty"`
+
+	// Not is true if its source state is false
+	Not *SourceState `yaml:"not,omitempty"`
+}
+
+// IsExpression reports whether the source state is an any, all or not expression
+func (s *SourceState) IsExpression() bool {
+	return s.Any != nil || s.All != nil || s.Not != nil
 }
 
+// Holds evaluates the source state against the states of the sources. Unknown sources never match.
+func (s *SourceState) Holds(status map[string]SourceStatus) bool {
+	switch {
+	case s.Not != nil:
+		return !s.Not.Holds(status)
+	case s.Any != nil:
+		for i := range s.Any {
+			if s.Any[i].Holds(status) {
+				return true
+			}
+		}
+		return false
+	case s.All != nil:
+		for i := range s.All {
+			if !s.All[i].Holds(status) {
+				return false
+			}
+		}
+		return true
+	}
+	current, known := status[s.SourceName]
+	return known && ConditionType(current) == s.ConditionType
+}
+
+// leaves returns the plain source states of a source state or expression, in the order they are listed
+func (s *SourceState) leaves() []SourceState {
+	if !s.IsExpression() {
+		return []SourceState{*s}
+	}
+	var leaves []SourceState
+	if s.Not != nil {
+		leaves = append(leaves, s.Not.leaves()...)
+	}
+	for _, operands := range [][]SourceState{s.Any, s.All} {
+		for i := range operands {
+			leaves = append(leaves, operands[i].leaves()...)
+		}
+	}
+	return leaves
+}
+
+func (s SourceState) String() string {
+	var operands []SourceState
+	var operator string
+	switch {
+	case s.Not != nil:
+		return "NOT " + s.Not.operand()
+	case s.Any != nil:
+		operands, operator = s.Any, " OR "
+	case s.All != nil:
+		operands, operator = s.All, " AND "
+	default:
+		return s.SourceName + " " + string(s.ConditionType)
+	}
+	parts := make([]string, len(operands))
+	for i, operand := range operands {
+		parts[i] = operand.operand()
+	}
+	return strings.Join(parts, operator)
+}
+
+// operand formats a source state as an operand of an expression, in parentheses if it combines several operands
+func (s SourceState) operand() string {
+	if len(s.Any) > 1 || len(s.All) > 1 {
+		return "(" + s.String() + ")"
+	}
+	return s.String()
+}
+
+const (
+	// DefaultSourceName is the source name of "default" conditions that are not tied to a source
+	DefaultSourceName = "Default on profile (re)load"
+
+	// InitSourceName is the source name of "init" conditions that are not tied to a source
+	InitSourceName = "Init on profile (re)load"
+)
+
 // DesiredState defines the desired pin and connector settings that are applied when a condition is triggered.
 type DesiredState struct {
-	// ClockID is the subsystem clock ID (decimal or hex format)
-	ClockID string `yaml:"clockId,omitempty"`
+	// ClockID is the subsystem clock ID (decimal, hex or alias)
+	ClockID ClockID `yaml:"clockId,omitempty"`
 
 	// BoardLabel and clock ID together unambiguously identify the subsystem and the DPLL pin,
 	// together with an optional external connector, if defined.
@@ -185,7 +340,7 @@ type PinState struct {
 	Priority *float64 `yaml:"priority,omitempty"`
 
 	// State is the pin desired state. Valid values: "connected", "disconnected", "selectable"
-	State string `yaml:"state,omitempty"`
+	State PinStateValue `yaml:"state,omitempty"`
 }
 
 // Subsystem defines an atomic synchronization subsystem of a single DPLL and one or more Ethernet subsystems linked together.
@@ -207,9 +362,10 @@ type Subsystem struct {
 // DPLL represents generic DPLL configuration within a synchronization subsystem.
 // Configuration of this section will result in DPLL device configurations through the Netlink driver.
 type DPLL struct {
-	// ClockID is an optional clock ID. If omitted, the hardware must support clock ID discovery.
-	// Format: decimal or hex ("5799633565432596414" or "0xaabbccfffeddeeff")
-	ClockID string `yaml:"clockId,omitempty"`
+	// ClockID is an optional clock ID. If omitted, it is discovered from the first Ethernet port of the
+	// subsystem when the configuration is loaded, see ClockChain.DiscoverClockIDs.
+	// Format: decimal, hex ("5799633565432596414" or "0xaabbccfffeddeeff") or alias. Zero if omitted.
+	ClockID ClockID `yaml:"clockId,omitempty"`
 
 	// PhaseInputs are phase reference input pins, keyed by board label
 	PhaseInputs map[string]PinConfig `yaml:"phaseInputs,omitempty"`
@@ -257,6 +413,19 @@ type PinConfig struct {
 	// ReferenceSync applies to frequency pins that can be paired to a phase pin by board label
 	// The value should match a phase pin label (from phaseInputs) within the same subsystem
 	ReferenceSync string `yaml:"referenceSync,omitempty"`
+
+	// RefSyncConfigName is an optional ref-sync configuration name (defined in CommonDefinitions).
+	// The related pin board label of the definition is the phase pin this frequency input is paired
+	// with, within the same subsystem. It is expanded into ReferenceSync when the configuration is loaded.
+	RefSyncConfigName string `yaml:"refSyncConfigName,omitempty"`
+}
+
+// referenceSyncKey returns the key the ref-sync pairing of a pin is configured with
+func (pc *PinConfig) referenceSyncKey() string {
+	if pc.RefSyncConfigName != "" {
+		return "refSyncConfigName"
+	}
+	return "referenceSync"
 }
 
 // PhaseAdjustment represents phase adjustment that must be applied to the input or the output pin
@@ -279,17 +448,10 @@ type PhaseAdjustment struct {
 
 // Custom validation functions
 
-// ValidateClockID validates clock ID format (decimal or hex)
+// ValidateClockID validates clock ID format (decimal or hex) and range (64 bits)
 func ValidateClockID(clockID string) error {
-	// Pattern: (?:(0[xX][0-9a-fA-F]+)|([0-9]))
-	hexPattern := regexp.MustCompile(`^0[xX][0-9a-fA-F]+$`)
-	decPattern := regexp.MustCompile(`^[0-9]+$`)
-
-	if hexPattern.MatchString(clockID) || decPattern.MatchString(clockID) {
-		return nil
-	}
-
-	return fmt.Errorf("invalid clock ID format: %s (must be decimal or hex)", clockID)
+	_, err := ParseClockID(clockID)
+	return err
 }
 
 // ValidateAlphanumDash validates alphanumeric characters with dashes and underscores
@@ -302,258 +464,630 @@ func ValidateAlphanumDash(value string) error {
 }
 
 // BuildClockAliasMap constructs a mapping from alias to clock ID and validates entries
-func (cc *ClockChain) BuildClockAliasMap() (map[string]string, error) {
-	aliasToClock := make(map[string]string)
+func (cc *ClockChain) BuildClockAliasMap() (map[string]ClockID, error) {
 	if cc.CommonDefinitions == nil {
-		return aliasToClock, nil
+		return make(map[string]ClockID), nil
 	}
+	return buildClockAliasMap(cc.CommonDefinitions.ClockIdentifiers)
+}
 
-	for _, ident := range cc.CommonDefinitions.ClockIdentifiers {
+// buildClockAliasMap constructs a mapping from alias to clock ID from clock identifiers
+func buildClockAliasMap(identifiers []ClockIdentifier) (map[string]ClockID, error) {
+	aliasToClock := make(map[string]ClockID)
+	for _, ident := range identifiers {
 		if ident.Alias == "" {
 			return nil, fmt.Errorf("clockIdentifiers: alias must not be empty")
 		}
 		if err := ValidateAlphanumDash(ident.Alias); err != nil {
 			return nil, fmt.Errorf("clockIdentifiers: invalid alias '%s': %w", ident.Alias, err)
 		}
-		if err := ValidateClockID(ident.ClockID); err != nil {
-			return nil, fmt.Errorf("clockIdentifiers: alias '%s' has invalid clockId: %w", ident.Alias, err)
+		clockID, err := ident.resolve()
+		if err != nil {
+			return nil, fmt.Errorf("clockIdentifiers: alias '%s' %w", ident.Alias, err)
 		}
 		if _, exists := aliasToClock[ident.Alias]; exists {
 			return nil, fmt.Errorf("clockIdentifiers: duplicate alias '%s'", ident.Alias)
 		}
-		aliasToClock[ident.Alias] = ident.ClockID
+		aliasToClock[ident.Alias] = clockID
 	}
 
 	return aliasToClock, nil
 }
 
-// resolveClockIDValue returns a validated clock ID string. If the input is not a valid
-// clock ID, it will try to resolve it as an alias using aliasToClock map.
-func resolveClockIDValue(value string, aliasToClock map[string]string) (string, error) {
-	if value == "" {
-		return value, nil
+// resolve returns the clock ID of the identifier, deriving it from the MAC address or the network
+// interface if the clock ID is not given
+func (ci ClockIdentifier) resolve() (ClockID, error) {
+	set := 0
+	for _, isSet := range []bool{ci.ClockID != 0, ci.MAC != "", ci.Interface != ""} {
+		if isSet {
+			set++
+		}
+	}
+	if set != 1 {
+		return 0, fmt.Errorf("must set exactly one of clockId, mac and interface")
+	}
+
+	var mac net.HardwareAddr
+	switch {
+	case ci.ClockID != 0:
+		return ci.ClockID, nil
+	case ci.MAC != "":
+		var err error
+		if mac, err = net.ParseMAC(ci.MAC); err != nil {
+			return 0, fmt.Errorf("has invalid mac: %w", err)
+		}
+	default:
+		var err error
+		if mac, err = readInterfaceMAC(SysfsRoot, ci.Interface); err != nil {
+			return 0, fmt.Errorf("has unresolvable interface: %w", err)
+		}
 	}
-	if err := ValidateClockID(value); err == nil {
-		return value, nil
+	clockID, err := eui64FromMAC(mac)
+	if err != nil {
+		return 0, fmt.Errorf("has invalid mac: %w", err)
+	}
+	return clockID, nil
+}
+
+// resolveClockIDValue parses a clock ID written in decimal or hex. If the value is not a valid
+// clock ID, it will try to resolve it as an alias using aliasToClock map.
+func resolveClockIDValue(value string, aliasToClock map[string]ClockID) (ClockID, error) {
+	clockID, err := ParseClockID(value)
+	if err == nil {
+		return clockID, nil
 	}
 	if resolved, ok := aliasToClock[value]; ok {
 		return resolved, nil
 	}
-	return "", fmt.Errorf("value '%s' is neither a valid clock ID nor a known alias", value)
+	return 0, fmt.Errorf("value '%s' is neither a valid clock ID (%v) nor a known alias", value, err)
+}
+
+// UnmarshalYAML decodes a configuration document, replacing the clock aliases used as clock IDs by
+// the clock IDs they stand for first, so that a plain yaml.Unmarshal accepts aliases as well
+func (cc *ClockChain) UnmarshalYAML(node *yaml.Node) error {
+	resolved := cloneNode(node, make(map[*yaml.Node]*yaml.Node))
+	if err := resolveClockAliases(resolved); err != nil {
+		return err
+	}
+	type fields ClockChain
+	return resolved.Decode((*fields)(cc))
 }
 
-// ResolveClockAliases walks the configuration and replaces any clock alias usages
-// with their corresponding clock IDs. This should be called early in the program
-// lifecycle before merges and validation.
+// ResolveClockAliases checks that every clock identifier of the configuration resolves to a clock
+// ID. The aliases used as clock IDs are replaced when the configuration is decoded, so this only
+// reports clock identifiers that are invalid, duplicated or whose interface cannot be read.
 func (cc *ClockChain) ResolveClockAliases() error {
-	aliasToClock, err := cc.BuildClockAliasMap()
+	_, err := cc.BuildClockAliasMap()
+	return err
+}
+
+// resolveClockAliases replaces the clock aliases used as clockId values of a configuration document
+// by the clock IDs they stand for, so that the document decodes into typed clock IDs. Values that
+// are neither clock IDs nor known aliases are reported with their path and position.
+func resolveClockAliases(root *yaml.Node) error {
+	var definitions struct {
+		CommonDefinitions struct {
+			ClockIdentifiers []ClockIdentifier `yaml:"clockIdentifiers"`
+		} `yaml:"commonDefinitions"`
+	}
+	if err := root.Decode(&definitions); err != nil {
+		return err
+	}
+	aliasToClock, err := buildClockAliasMap(definitions.CommonDefinitions.ClockIdentifiers)
 	if err != nil {
 		return err
 	}
 
-	// Resolve DPLL clock IDs
-	for si := range cc.Structure {
-		if cc.Structure[si].DPLL.ClockID != "" {
-			resolved, err := resolveClockIDValue(cc.Structure[si].DPLL.ClockID, aliasToClock)
-			if err != nil {
-				return fmt.Errorf("structure[%d] DPLL.clockId: %w", si, err)
-			}
-			cc.Structure[si].DPLL.ClockID = resolved
+	var vc validationCollector
+	walkClockIDs(root, "", func(node *yaml.Node, path string) {
+		clockID, err := resolveClockIDValue(node.Value, aliasToClock)
+		if err != nil {
+			vc.add(RuleClockIDFormat, path, "%v", err)
+			last := &vc.errs[len(vc.errs)-1]
+			last.Line, last.Column = node.Line, node.Column
+			return
 		}
+		node.Value, node.Tag, node.Style = clockID.String(), "!!str", 0
+	})
+	if err := vc.result(); err != nil {
+		vc.errs.Sort()
+		return vc.errs
 	}
+	return nil
+}
 
-	if cc.Behavior == nil {
-		return nil
+// walkClockIDs calls visit for every scalar clockId value below node, skipping extension keys
+func walkClockIDs(node *yaml.Node, path string, visit func(node *yaml.Node, path string)) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			walkClockIDs(child, path, visit)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i].Value, node.Content[i+1]
+			switch {
+			case strings.HasPrefix(key, ExtensionKeyPrefix):
+			case key == "clockId" && value.Kind == yaml.ScalarNode:
+				visit(value, pathKey(path, key))
+			default:
+				walkClockIDs(value, pathKey(path, key), visit)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, item := range node.Content {
+			walkClockIDs(item, pathIndex(path, i), visit)
+		}
 	}
+}
 
-	// Resolve source clock IDs
-	for i := range cc.Behavior.Sources {
-		resolved, err := resolveClockIDValue(cc.Behavior.Sources[i].ClockID, aliasToClock)
-		if err != nil {
-			return fmt.Errorf("behavior.sources[%d].clockId: %w", i, err)
+// ExpandRefSyncDefinitions sets the referenceSync of every pin that references a refSync definition
+// by refSyncConfigName to the related pin board label of the definition. Pins that set referenceSync
+// themselves, and references to unknown definitions, are left for validation to report.
+func (cc *ClockChain) ExpandRefSyncDefinitions() {
+	if cc.CommonDefinitions == nil {
+		return
+	}
+	related := make(map[string]string)
+	for _, definition := range cc.CommonDefinitions.RefSyncDefinitions {
+		if _, exists := related[definition.Name]; !exists {
+			related[definition.Name] = definition.RelatedPinBoardLabel
 		}
-		cc.Behavior.Sources[i].ClockID = resolved
 	}
-
-	// Resolve desired state clock IDs
-	for ci := range cc.Behavior.Conditions {
-		for di := range cc.Behavior.Conditions[ci].DesiredStates {
-			resolved, err := resolveClockIDValue(cc.Behavior.Conditions[ci].DesiredStates[di].ClockID, aliasToClock)
-			if err != nil {
-				return fmt.Errorf("behavior.conditions[%d].desiredStates[%d].clockId: %w", ci, di, err)
+	for si := range cc.Structure {
+		for _, group := range pinGroups {
+			pins := group.pins(&cc.Structure[si].DPLL)
+			for label, config := range pins {
+				if config.RefSyncConfigName != "" && config.ReferenceSync == "" && related[config.RefSyncConfigName] != "" {
+					config.ReferenceSync = related[config.RefSyncConfigName]
+					pins[label] = config
+				}
 			}
-			cc.Behavior.Conditions[ci].DesiredStates[di].ClockID = resolved
 		}
 	}
-
-	return nil
 }
 
 // ValidatePinConfig ensures frequency and esyncConfigName are mutually exclusive
 func (pc *PinConfig) Validate() error {
+	var vc validationCollector
+	pc.validate(&vc, "")
+	return vc.result()
+}
+
+// validate records pin config findings under the given path
+func (pc *PinConfig) validate(vc *validationCollector, path string) {
 	if pc.Frequency != nil && pc.ESyncConfigName != "" {
-		return fmt.Errorf("frequency and esyncConfigName are mutually exclusive")
+		vc.add(RulePinConfig, path, "frequency and esyncConfigName are mutually exclusive")
 	}
 
 	if pc.Connector != "" {
 		if err := ValidateAlphanumDash(pc.Connector); err != nil {
-			return fmt.Errorf("invalid connector format: %w", err)
+			vc.add(RuleConnectorFormat, pathKey(path, "connector"), "invalid connector format: %v", err)
 		}
 	}
-
-	return nil
 }
 
 // ValidateSourceConfig ensures PTPTimeReceivers is specified when sourceType is ptpTimeReceiver
 func (sc *SourceConfig) Validate() error {
-	if err := ValidateClockID(sc.ClockID); err != nil {
-		return fmt.Errorf("invalid clock ID: %w", err)
+	var vc validationCollector
+	sc.validate(&vc, "")
+	return vc.result()
+}
+
+// validate records source config findings under the given path
+func (sc *SourceConfig) validate(vc *validationCollector, path string) {
+	if sc.ClockID == 0 {
+		vc.add(RuleClockIDFormat, pathKey(path, "clockId"), "clockId must be specified")
 	}
 
-	if sc.SourceType == "ptpTimeReceiver" && len(sc.PTPTimeReceivers) == 0 {
-		return fmt.Errorf("ptpTimeReceivers must be specified when sourceType is ptpTimeReceiver")
+	if sc.SourceType == SourceTypePTPTimeReceiver && len(sc.PTPTimeReceivers) == 0 {
+		vc.add(RulePTPTimeReceivers, path, "ptpTimeReceivers must be specified when sourceType is ptpTimeReceiver")
 	}
 
-	for _, receiver := range sc.PTPTimeReceivers {
+	for i, receiver := range sc.PTPTimeReceivers {
 		if err := ValidateAlphanumDash(receiver); err != nil {
-			return fmt.Errorf("invalid PTP time receiver format: %w", err)
+			vc.add(RulePTPTimeReceivers, pathIndex(pathKey(path, "ptpTimeReceivers"), i),
+				"invalid PTP time receiver format: %v", err)
 		}
 	}
-
-	return nil
 }
 
-// ValidateClockChain performs comprehensive validation of the entire configuration
+// ValidateClockChain performs comprehensive validation of the entire configuration.
+// Validation does not stop at the first problem: if any finding has error severity, the returned
+// error is a ValidationErrors list containing every error and warning, each with a rule ID and the
+// path of the offending entry. Use Findings to get advisory findings of valid configurations.
 func (cc *ClockChain) Validate() error {
+	return cc.ValidateWithPlugins(nil)
+}
+
+// ValidateWithPlugins validates the configuration like Validate, additionally accepting pins that are
+// known to the hardware plugin of a subsystem as targets for sources and desired states.
+// The plugin manager may be nil.
+func (cc *ClockChain) ValidateWithPlugins(pm *PluginManager) error {
+	findings := cc.Findings(pm)
+	if !findings.HasErrors() {
+		return nil
+	}
+	return findings.Filter(SeverityWarning)
+}
+
+// Findings returns every validation finding of the configuration, including warnings and info
+// findings, which do not make it invalid. The plugin manager may be nil.
+func (cc *ClockChain) Findings(pm *PluginManager) ValidationErrors {
+	var vc validationCollector
+
 	// Validate that structure has at least one subsystem
 	if len(cc.Structure) == 0 {
-		return fmt.Errorf("structure must contain at least one subsystem")
+		vc.add(RuleStructureEmpty, "structure", "structure must contain at least one subsystem")
 	}
 
 	// Collect all clock IDs and source names for cross-reference validation
-	clockIDs := make(map[string]bool)
+	clockIDs := make(map[ClockID]int)
+	portOwners := make(map[string]int)
 	sourceNames := make(map[string]bool)
 	esyncNames := make(map[string]bool)
-	refsyncNames := make(map[string]bool)
+	esyncConfigs := make(map[string]ESyncConfig)
+	refsyncDefinitions := make(map[string]RefSyncDefinition)
 
 	// Collect eSync definition names
 	if cc.CommonDefinitions != nil {
-		for _, esync := range cc.CommonDefinitions.ESyncDefinitions {
+		for i, esync := range cc.CommonDefinitions.ESyncDefinitions {
+			path := pathIndex("commonDefinitions.eSyncDefinitions", i)
 			if esync.Name == "" {
-				return fmt.Errorf("eSync definition name must not be empty")
+				vc.add(RuleDefinitionName, path, "eSync definition name must not be empty")
+				continue
 			}
 			if esyncNames[esync.Name] {
-				return fmt.Errorf("duplicate eSync definition name: %s", esync.Name)
+				vc.add(RuleDefinitionName, pathKey(path, "name"), "duplicate eSync definition name: %s", esync.Name)
 			}
 			esyncNames[esync.Name] = true
+			esyncConfigs[esync.Name] = esync.ESyncConfig
 		}
-		for _, refsync := range cc.CommonDefinitions.RefSyncDefinitions {
+		for i, refsync := range cc.CommonDefinitions.RefSyncDefinitions {
+			path := pathIndex("commonDefinitions.refSyncDefinitions", i)
 			if refsync.Name == "" {
-				return fmt.Errorf("refSync definition name must not be empty")
+				vc.add(RuleDefinitionName, path, "refSync definition name must not be empty")
+				continue
 			}
-			if refsyncNames[refsync.Name] {
-				return fmt.Errorf("duplicate refSync definition name: %s", refsync.Name)
+			if _, exists := refsyncDefinitions[refsync.Name]; exists {
+				vc.add(RuleDefinitionName, pathKey(path, "name"), "duplicate refSync definition name: %s", refsync.Name)
 			}
-			refsyncNames[refsync.Name] = true
+			refsyncDefinitions[refsync.Name] = refsync
 		}
 	}
 
 	// Validate subsystems and collect clock IDs
-	for _, subsystem := range cc.Structure {
-		if subsystem.DPLL.ClockID != "" {
-			if err := ValidateClockID(subsystem.DPLL.ClockID); err != nil {
-				return fmt.Errorf("invalid clock ID in subsystem %s: %w", subsystem.Name, err)
+	for si, subsystem := range cc.Structure {
+		subsystemPath := pathIndex("structure", si)
+		if clockID := subsystem.DPLL.ClockID; clockID != 0 {
+			if owner, exists := clockIDs[clockID]; exists {
+				vc.add(RuleClockIDDuplicate, subsystemPath+".dpll.clockId", "clock ID %s of subsystem %s is already used by subsystem %s",
+					clockID, subsystem.Name, cc.Structure[owner].Name)
+			} else {
+				clockIDs[clockID] = si
+			}
+		}
+
+		// Each Ethernet port belongs to exactly one subsystem
+		for ei, ethernet := range subsystem.Ethernet {
+			for pi, port := range ethernet.Ports {
+				if owner, exists := portOwners[port]; exists {
+					if owner != si {
+						vc.add(RuleEthernetPort, pathIndex(pathIndex(subsystemPath+".ethernet", ei)+".ports", pi),
+							"Ethernet port %s is already listed in subsystem %s", port, cc.Structure[owner].Name)
+					}
+					continue
+				}
+				portOwners[port] = si
 			}
-			clockIDs[subsystem.DPLL.ClockID] = true
+		}
+
+		if pm != nil && subsystem.HardwarePlugin != "" && pm.GetPlugin(subsystem.HardwarePlugin) == nil {
+			vc.warn(RuleUnknownPlugin, subsystemPath+".hardwarePlugin",
+				"hardware plugin %s of subsystem %s is not loaded, plugin pins and defaults are not available",
+				subsystem.HardwarePlugin, subsystem.Name)
+		}
+
+		var plugin *HardwarePluginConfig
+		if pm != nil && subsystem.HardwarePlugin != "" {
+			plugin = pm.GetPlugin(subsystem.HardwarePlugin)
 		}
 
 		// Validate pin configs
-		allPinConfigs := make(map[string]PinConfig)
 		phaseLabels := make(map[string]struct{})
 		freqInputLabels := make(map[string]struct{})
 		freqOutputLabels := make(map[string]struct{})
 
-		for label, config := range subsystem.DPLL.PhaseInputs {
-			allPinConfigs[label] = config
+		for label := range subsystem.DPLL.PhaseInputs {
 			phaseLabels[label] = struct{}{}
 		}
-		for label, config := range subsystem.DPLL.PhaseOutputs {
-			allPinConfigs[label] = config
+		for label := range subsystem.DPLL.PhaseOutputs {
 			phaseLabels[label] = struct{}{}
 		}
-		for label, config := range subsystem.DPLL.FrequencyInputs {
-			allPinConfigs[label] = config
+		for label := range subsystem.DPLL.FrequencyInputs {
 			freqInputLabels[label] = struct{}{}
 		}
-		for label, config := range subsystem.DPLL.FrequencyOutputs {
-			allPinConfigs[label] = config
+		for label := range subsystem.DPLL.FrequencyOutputs {
 			freqOutputLabels[label] = struct{}{}
 		}
 
-		for label, config := range allPinConfigs {
-			if err := config.Validate(); err != nil {
-				return fmt.Errorf("invalid pin config %s in subsystem %s: %w", label, subsystem.Name, err)
-			}
+		for _, group := range pinGroups {
+			groupPins := group.pins(&subsystem.DPLL)
+			for _, label := range sortedPinLabels(groupPins) {
+				config := groupPins[label]
+				pinPath := pathKey(subsystemPath+".dpll."+group.name, label)
 
-			// Check if referenced eSync config exists
-			if config.ESyncConfigName != "" && !esyncNames[config.ESyncConfigName] {
-				return fmt.Errorf("referenced eSync config %s not found in subsystem %s, pin %s",
-					config.ESyncConfigName, subsystem.Name, label)
-			}
+				config.validate(&vc, pinPath)
+				if config.Description == "" {
+					vc.info(RulePinDescription, pinPath, "pin %s of subsystem %s has no description", label, subsystem.Name)
+				}
+
+				// Resolve the pairing of a referenced refSync definition, unless it is already expanded
+				refKey := config.referenceSyncKey()
+				refPath := pathKey(pinPath, refKey)
+				if config.RefSyncConfigName != "" {
+					definition, exists := refsyncDefinitions[config.RefSyncConfigName]
+					switch {
+					case !exists:
+						vc.add(RuleReferenceSync, refPath, "referenced refSync config %s not found in subsystem %s, pin %s",
+							config.RefSyncConfigName, subsystem.Name, label)
+					case definition.RelatedPinBoardLabel == "":
+						vc.add(RuleReferenceSync, refPath, "refSync definition %s referenced by pin %s has no relatedPinBoardLabel",
+							config.RefSyncConfigName, label)
+					case config.ReferenceSync == "":
+						config.ReferenceSync = definition.RelatedPinBoardLabel
+					case config.ReferenceSync != definition.RelatedPinBoardLabel:
+						vc.add(RuleReferenceSync, refPath, "referenceSync %s of pin %s conflicts with refSync definition %s, which pairs it with %s",
+							config.ReferenceSync, label, config.RefSyncConfigName, definition.RelatedPinBoardLabel)
+					}
+				}
 
-			// Validate referenceSync semantics: only allowed on frequency INPUT pins and must reference an existing phase pin
-			if config.ReferenceSync != "" {
-				if _, isFreqInput := freqInputLabels[label]; !isFreqInput {
-					if _, isFreqOutput := freqOutputLabels[label]; isFreqOutput {
-						return fmt.Errorf("referenceSync is not supported on frequency output pin %s in subsystem %s", label, subsystem.Name)
+				// Check the pin against the capabilities declared by the hardware plugin
+				if plugin != nil {
+					if caps, ok := plugin.Pins[label]; ok {
+						caps.validatePinConfig(&vc, pinPath, label, group.direction, group.kind, config, esyncConfigs, plugin.PluginInfo.Name)
 					}
-					return fmt.Errorf("referenceSync specified on non-frequency-input pin %s in subsystem %s", label, subsystem.Name)
 				}
-				if _, exists := phaseLabels[config.ReferenceSync]; !exists {
-					return fmt.Errorf("referenceSync '%s' not found among phase pins in subsystem %s (referenced by %s)",
-						config.ReferenceSync, subsystem.Name, label)
+
+				// Check if referenced eSync config exists
+				if config.ESyncConfigName != "" && !esyncNames[config.ESyncConfigName] {
+					vc.add(RuleESyncReference, pathKey(pinPath, "esyncConfigName"),
+						"referenced eSync config %s not found in subsystem %s, pin %s",
+						config.ESyncConfigName, subsystem.Name, label)
+				}
+
+				// Validate referenceSync semantics: only allowed on frequency INPUT pins and must reference an existing phase pin
+				if config.ReferenceSync != "" {
+					if _, isFreqInput := freqInputLabels[label]; !isFreqInput {
+						if _, isFreqOutput := freqOutputLabels[label]; isFreqOutput {
+							vc.add(RuleReferenceSync, refPath,
+								"%s is not supported on frequency output pin %s in subsystem %s", refKey, label, subsystem.Name)
+						} else {
+							vc.add(RuleReferenceSync, refPath,
+								"%s specified on non-frequency-input pin %s in subsystem %s", refKey, label, subsystem.Name)
+						}
+					} else if _, exists := phaseLabels[config.ReferenceSync]; !exists {
+						vc.add(RuleReferenceSync, refPath,
+							"referenceSync '%s' not found among phase pins in subsystem %s (referenced by %s)",
+							config.ReferenceSync, subsystem.Name, label)
+					}
 				}
 			}
 		}
 	}
 
+	pins := NewPinIndex(cc, pm)
+
 	// Validate behavior section if present
 	if cc.Behavior != nil {
 		// Collect source names and validate sources
-		for _, source := range cc.Behavior.Sources {
-			if err := source.Validate(); err != nil {
-				return fmt.Errorf("invalid source %s: %w", source.Name, err)
+		for i, source := range cc.Behavior.Sources {
+			sourcePath := pathIndex("behavior.sources", i)
+			source.validate(&vc, sourcePath)
+
+			// Sources are received on input pins
+			if pin := resolvePinReference(&vc, pins, sourcePath, source.ClockID, source.BoardLabel); pin != nil &&
+				pin.Direction == PinDirectionOutput {
+				vc.add(RulePinDirection, pathKey(sourcePath, "boardLabel"),
+					"source %s is received on output pin %s of subsystem %s", source.Name, source.BoardLabel, pin.Subsystem.Name)
+			}
+
+			// PTP time receivers must be Ethernet ports of the subsystem receiving the source
+			if subsystem := pins.Subsystem(source.ClockID); subsystem != nil {
+				for ri, receiver := range source.PTPTimeReceivers {
+					receiverPath := pathIndex(sourcePath+".ptpTimeReceivers", ri)
+					owner, exists := portOwners[receiver]
+					if !exists {
+						vc.add(RuleReceiverPort, receiverPath,
+							"PTP time receiver %s of source %s is not an Ethernet port of any subsystem", receiver, source.Name)
+					} else if &cc.Structure[owner] != subsystem {
+						vc.add(RuleReceiverPort, receiverPath,
+							"PTP time receiver %s of source %s belongs to subsystem %s, not to subsystem %s (clock ID %s)",
+							receiver, source.Name, cc.Structure[owner].Name, subsystem.Name, source.ClockID)
+					}
+				}
 			}
 
 			if sourceNames[source.Name] {
-				return fmt.Errorf("duplicate source name: %s", source.Name)
+				vc.add(RuleSourceName, pathKey(sourcePath, "name"), "duplicate source name: %s", source.Name)
 			}
 			sourceNames[source.Name] = true
 		}
 
-		// Validate conditions
-		for _, condition := range cc.Behavior.Conditions {
-			for _, sourceState := range condition.Sources {
-				// Check if referenced source exists (unless it's a special default source)
-				if sourceState.SourceName != "Default on profile (re)load" &&
-					!sourceNames[sourceState.SourceName] {
-					return fmt.Errorf("referenced source %s not found in condition %s",
-						sourceState.SourceName, condition.Name)
+		// Validate conditions. The default conditions come first, followed by the init condition and
+		// then by the conditions evaluated against source states.
+		initCondition, sourceCondition := "", ""
+		for ci, condition := range cc.Behavior.Conditions {
+			conditionPath := cc.conditionPath(ci)
+			switch {
+			case isDefaultCondition(&condition):
+				if initCondition != "" {
+					vc.add(RuleConditionOrder, conditionPath,
+						"default condition %s must be listed before the init condition %s", condition.Name, initCondition)
+				}
+			case isInitCondition(&condition):
+				if initCondition != "" {
+					vc.add(RuleConditionType, pathIndex(conditionPath+".sources", 0)+".conditionType",
+						"duplicate init condition %s, %s is already the init condition", condition.Name, initCondition)
+				} else {
+					initCondition = condition.Name
+				}
+				if sourceCondition != "" {
+					vc.add(RuleConditionOrder, conditionPath,
+						"init condition %s must be listed before condition %s and any other locked or lost condition", condition.Name, sourceCondition)
 				}
+			case sourceCondition == "":
+				sourceCondition = condition.Name
+			}
+			for si := range condition.Sources {
+				sourceState := &condition.Sources[si]
+				statePath := pathIndex(conditionPath+".sources", si)
+				// The trigger is a single source entering a state, so it cannot be an expression
+				if si == 0 && sourceState.IsExpression() {
+					vc.add(RuleSourceExpression, statePath,
+						"the triggering source of condition %s must be a source state, not an any, all or not expression", condition.Name)
+					continue
+				}
+				validateSourceState(&vc, sourceState, statePath, condition.Name, sourceNames, si == 0)
 			}
 
 			// Validate desired states
-			for _, desiredState := range condition.DesiredStates {
-				if desiredState.ClockID != "" {
-					if err := ValidateClockID(desiredState.ClockID); err != nil {
-						return fmt.Errorf("invalid clock ID in desired state: %w", err)
+			for di, desiredState := range condition.DesiredStates {
+				statePath := pathIndex(conditionPath+".desiredStates", di)
+				pin := resolvePinReference(&vc, pins, statePath, desiredState.ClockID, desiredState.BoardLabel)
+				if pin == nil {
+					continue
+				}
+
+				// Input pins are controlled through priority, output pins through state
+				for _, pinState := range []struct {
+					name  string
+					state *PinState
+				}{{"eec", desiredState.EEC}, {"pps", desiredState.PPS}} {
+					if pinState.state == nil {
+						continue
+					}
+					if pinState.state.Priority != nil && pin.Direction == PinDirectionOutput {
+						vc.add(RulePinDirection, statePath+"."+pinState.name+".priority",
+							"priority can only be set on input pins, %s is an output of subsystem %s",
+							desiredState.BoardLabel, pin.Subsystem.Name)
+					}
+					if pinState.state.State != "" && pin.Direction == PinDirectionInput {
+						vc.add(RulePinDirection, statePath+"."+pinState.name+".state",
+							"state can only be set on output pins, %s is an input of subsystem %s",
+							desiredState.BoardLabel, pin.Subsystem.Name)
 					}
 				}
 			}
 		}
 	}
 
-	return nil
+	// Validate the links between subsystems
+	topology, errs := BuildTopology(cc, pins)
+	vc.errs = append(vc.errs, errs...)
+	topology.validate(&vc, cc, pins)
+
+	return vc.errs
+}
+
+// conditionPath returns the path of a behavior condition in the source document. Conditions generated
+// from plugin defaults are listed among the user conditions without being part of the document, so
+// they are identified by name instead of by index.
+func (cc *ClockChain) conditionPath(index int) string {
+	condition := &cc.Behavior.Conditions[index]
+	if condition.generated {
+		return pathKey("behavior.conditions", condition.Name)
+	}
+	source := 0
+	for i := 0; i < index; i++ {
+		if !cc.Behavior.Conditions[i].generated {
+			source++
+		}
+	}
+	return pathIndex("behavior.conditions", source)
+}
+
+// resolvePinReference resolves the (clockId, boardLabel) pair of a source or desired state to a pin,
+// recording a finding and returning nil if it does not match a subsystem or one of its pins
+func resolvePinReference(vc *validationCollector, pins *PinIndex, path string, clockID ClockID, boardLabel string) *PinInfo {
+	if clockID == 0 {
+		vc.add(RuleUnknownPin, path, "clockId must be specified to identify pin %s", boardLabel)
+		return nil
+	}
+	subsystem := pins.Subsystem(clockID)
+	if subsystem == nil {
+		vc.add(RuleUnknownClockID, pathKey(path, "clockId"), "clock ID %s does not match any subsystem DPLL", clockID)
+		return nil
+	}
+	if boardLabel == "" {
+		vc.add(RuleUnknownPin, path, "boardLabel must be specified")
+		return nil
+	}
+	pin, ok := pins.Lookup(clockID, boardLabel)
+	if !ok {
+		vc.add(RuleUnknownPin, pathKey(path, "boardLabel"),
+			"board label %s does not match any pin of subsystem %s (clock ID %s)",
+			boardLabel, subsystem.Name, clockID)
+		return nil
+	}
+	return pin
+}
+
+// validateSourceState checks a source state or expression of a condition. Only the triggering source
+// state may use the "default" and "init" condition types, since those are not evaluated against source states.
+func validateSourceState(vc *validationCollector, state *SourceState, path, conditionName string, sourceNames map[string]bool, trigger bool) {
+	operators := 0
+	for _, set := range []bool{state.Any != nil, state.All != nil, state.Not != nil} {
+		if set {
+			operators++
+		}
+	}
+	leaf := state.SourceName != "" || state.ConditionType != ""
+	if operators > 1 || (operators == 1 && leaf) {
+		vc.add(RuleSourceExpression, path,
+			"source state of condition %s must have either sourceName and conditionType or exactly one of any, all and not", conditionName)
+		return
+	}
+
+	switch {
+	case state.Not != nil:
+		validateSourceState(vc, state.Not, path+".not", conditionName, sourceNames, false)
+	case state.Any != nil || state.All != nil:
+		operator, operands := "any", state.Any
+		if state.All != nil {
+			operator, operands = "all", state.All
+		}
+		if len(operands) == 0 {
+			vc.add(RuleSourceExpression, pathKey(path, operator), "%s expression of condition %s has no source states", operator, conditionName)
+		}
+		for i := range operands {
+			validateSourceState(vc, &operands[i], pathIndex(pathKey(path, operator), i), conditionName, sourceNames, false)
+		}
+	default:
+		if state.SourceName == "" || state.ConditionType == "" {
+			vc.add(RuleSourceExpression, path, "source state of condition %s must have sourceName and conditionType", conditionName)
+			return
+		}
+		if !trigger && (state.ConditionType == ConditionTypeDefault || state.ConditionType == ConditionTypeInit) {
+			vc.add(RuleConditionType, path+".conditionType",
+				"conditionType %s can only be used by the triggering source of condition %s", state.ConditionType, conditionName)
+		}
+		// Check if referenced source exists (unless it's a special default source)
+		if state.SourceName != DefaultSourceName && state.SourceName != InitSourceName && !sourceNames[state.SourceName] {
+			vc.add(RuleConditionSource, path+".sourceName",
+				"referenced source %s not found in condition %s", state.SourceName, conditionName)
+		}
+	}
+}
+
+// sortedPinLabels returns the board labels of a pin map in a stable order
+func sortedPinLabels(pins map[string]PinConfig) []string {
+	labels := make([]string, 0, len(pins))
+	for label := range pins {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	return labels
 }
 
 // String methods for pretty printing
@@ -596,8 +1130,8 @@ type PluginInfo struct {
 
 // PluginPinDefaults defines default pin configurations for a hardware plugin
 type PluginPinDefaults struct {
-	Priority *float64 `yaml:"priority,omitempty"`
-	State    string   `yaml:"state,omitempty"`
+	Priority *float64      `yaml:"priority,omitempty"`
+	State    PinStateValue `yaml:"state,omitempty"`
 }
 
 // PluginSpecificDefaults defines specific pin overrides for common pin names
@@ -606,11 +1140,48 @@ type PluginSpecificDefaults map[string]struct {
 	PPS *PluginPinDefaults `yaml:"pps,omitempty"`
 }
 
+// PluginPinCapabilities declares what the hardware can do with a pin, keyed by board label in the plugin file.
+// User pin configurations are validated against these capabilities.
+type PluginPinCapabilities struct {
+	// Direction is the pin direction: "input" or "output"
+	Direction PinDirection `yaml:"direction"`
+
+	// Kind is the pin signal kind: "phase" or "frequency"
+	Kind PinKind `yaml:"kind"`
+
+	// Frequencies lists the supported frequencies in Hz. If omitted, any frequency is accepted.
+	// For eSync pins, the eSync transfer frequency must also be listed.
+	Frequencies []float64 `yaml:"frequencies,omitempty"`
+
+	// ESync is true if the pin supports eSync (esyncConfigName)
+	ESync bool `yaml:"esync,omitempty"`
+
+	// RefSyncPair is the board label of the phase pin this frequency input can be ref-sync paired with
+	RefSyncPair string `yaml:"refSyncPair,omitempty"`
+
+	// PhaseAdjust is the supported phase adjustment range. If omitted, phase adjustment is not programmable.
+	PhaseAdjust *PluginPhaseAdjustRange `yaml:"phaseAdjust,omitempty"`
+
+	// Connectors lists the connectors the pin can be routed to. If omitted, the pin has no external connector.
+	Connectors []string `yaml:"connectors,omitempty"`
+}
+
+// PluginPhaseAdjustRange is the phase adjustment range supported by a pin, in picoseconds
+type PluginPhaseAdjustRange struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+
+	// Granularity is the adjustment step; the applied adjustment must be a multiple of it. Default: 1
+	Granularity int `yaml:"granularity,omitempty"`
+}
+
 // HardwarePluginConfig represents a complete hardware plugin configuration file
 type HardwarePluginConfig struct {
-	PluginInfo       PluginInfo             `yaml:"pluginInfo"`
-	SpecificDefaults PluginSpecificDefaults `yaml:"specificDefaults,omitempty"`
-	BehaviorNotes    string                 `yaml:"behaviorNotes,omitempty"`
+	PluginInfo       PluginInfo                       `yaml:"pluginInfo"`
+	Pins             map[string]PluginPinCapabilities `yaml:"pins,omitempty"`
+	SpecificDefaults PluginSpecificDefaults           `yaml:"specificDefaults,omitempty"`
+	InitDefaults     PluginSpecificDefaults           `yaml:"initDefaults,omitempty"`
+	BehaviorNotes    string                           `yaml:"behaviorNotes,omitempty"`
 }
 
 // PluginManager handles loading and applying hardware plugin defaults
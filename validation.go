@@ -0,0 +1,402 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Severity classifies how serious a validation finding is
+type Severity string
+
+const (
+	// SeverityError marks findings that make the configuration unusable
+	SeverityError Severity = "error"
+
+	// SeverityWarning marks findings that are suspicious but do not prevent the configuration from being applied
+	SeverityWarning Severity = "warning"
+
+	// SeverityInfo marks advisory findings, e.g. missing documentation
+	SeverityInfo Severity = "info"
+)
+
+// Severities are the valid severities, from the most to the least serious
+var Severities = []Severity{SeverityError, SeverityWarning, SeverityInfo}
+
+// ParseSeverity parses a severity name
+func ParseSeverity(name string) (Severity, error) {
+	for _, severity := range Severities {
+		if string(severity) == name {
+			return severity, nil
+		}
+	}
+	names := make([]string, len(Severities))
+	for i, severity := range Severities {
+		names[i] = string(severity)
+	}
+	return "", fmt.Errorf("invalid severity %q%s, valid values: %s", name, didYouMean(name, names), strings.Join(names, ", "))
+}
+
+// String implements flag.Value
+func (s *Severity) String() string {
+	if s == nil {
+		return ""
+	}
+	return string(*s)
+}
+
+// Set implements flag.Value
+func (s *Severity) Set(value string) error {
+	severity, err := ParseSeverity(value)
+	if err != nil {
+		return err
+	}
+	*s = severity
+	return nil
+}
+
+// AtLeast reports whether a severity is as serious as another one or more
+func (s Severity) AtLeast(other Severity) bool {
+	return s.rank() >= other.rank()
+}
+
+// rank orders severities, info being the lowest
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 2
+	case SeverityWarning:
+		return 1
+	}
+	return 0
+}
+
+// Rule IDs identify the check that produced a validation finding. They are stable and can be
+// used to filter findings.
+const (
+	RuleSchema           = "schema"
+	RuleStructureEmpty   = "structure-empty"
+	RuleClockIDFormat    = "clock-id-format"
+	RuleClockIDDuplicate = "clock-id-duplicate"
+	RuleDefinitionName   = "definition-name"
+	RulePinConfig        = "pin-config"
+	RuleConnectorFormat  = "connector-format"
+	RuleESyncReference   = "esync-reference"
+	RuleReferenceSync    = "reference-sync"
+	RulePTPTimeReceivers = "ptp-time-receivers"
+	RuleSourceName       = "source-name"
+	RuleConditionSource  = "condition-source"
+	RuleConditionType    = "condition-type"
+	RuleConditionOrder   = "condition-order"
+	RuleSourceExpression = "source-expression"
+	RuleUnknownPlugin    = "unknown-plugin"
+	RuleUnknownClockID   = "unknown-clock-id"
+	RuleUnknownPin       = "unknown-pin"
+	RulePinDirection     = "pin-direction"
+	RuleEthernetPort     = "ethernet-port"
+	RuleReceiverPort     = "ptp-receiver-port"
+	RuleHWDirection      = "hw-direction"
+	RuleHWFrequency      = "hw-frequency"
+	RuleHWESync          = "hw-esync"
+	RuleHWReferenceSync  = "hw-reference-sync"
+	RuleHWPhaseAdjust    = "hw-phase-adjust"
+	RuleHWConnector      = "hw-connector"
+	RuleLinkEndpoint     = "link-endpoint"
+	RuleLinkDirection    = "link-direction"
+	RuleLinkInference    = "link-inference"
+	RuleLinkSignal       = "link-signal"
+	RuleDanglingOutput   = "dangling-output"
+	RuleUndrivenInput    = "undriven-input"
+	RuleTopologyLoop     = "topology-loop"
+	RuleClockIDDiscovery = "clock-id-discovery"
+	RuleUnknownField     = "unknown-field"
+	RulePinDescription   = "pin-description"
+)
+
+// ValidationError is a single validation finding, located by its path in the configuration
+// and, when the source document is known, by its line and column
+type ValidationError struct {
+	// Severity of the finding
+	Severity Severity `json:"severity"`
+
+	// Rule is the stable ID of the check that produced the finding
+	Rule string `json:"rule"`
+
+	// Path is the location of the offending value, e.g. structure[1].dpll.phaseInputs.SMA1
+	Path string `json:"path"`
+
+	// Line and Column are the 1-based position in the source document, or zero if unknown
+	Line   int `json:"line,omitempty"`
+	Column int `json:"column,omitempty"`
+
+	// Message describes the problem
+	Message string `json:"message"`
+}
+
+func (e ValidationError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s [%s] ", e.Severity, e.Rule))
+	if e.Path != "" {
+		sb.WriteString(e.Path)
+	} else {
+		sb.WriteString("<root>")
+	}
+	if e.Line > 0 {
+		sb.WriteString(fmt.Sprintf(" (line %d, column %d)", e.Line, e.Column))
+	}
+	sb.WriteString(": ")
+	sb.WriteString(e.Message)
+	return sb.String()
+}
+
+// ValidationErrors is a list of validation findings. It implements error so that it can be
+// returned from Validate methods.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, e := range errs {
+		messages[i] = e.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// HasErrors reports whether any finding has error severity
+func (errs ValidationErrors) HasErrors() bool {
+	for _, e := range errs {
+		if e.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// HasSeverity reports whether any finding is at least as serious as the given severity
+func (errs ValidationErrors) HasSeverity(severity Severity) bool {
+	for _, e := range errs {
+		if e.Severity.AtLeast(severity) {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the findings that are at least as serious as the given severity
+func (errs ValidationErrors) Filter(severity Severity) ValidationErrors {
+	var filtered ValidationErrors
+	for _, e := range errs {
+		if e.Severity.AtLeast(severity) {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// Locate fills in the line and column of every finding that does not have one yet, by
+// following its path through the YAML node tree of the source document. If the path cannot be
+// followed to the end (e.g. for entries added by plugin defaults), the closest existing parent is used.
+func (errs ValidationErrors) Locate(root *yaml.Node) {
+	if root == nil {
+		return
+	}
+	for i := range errs {
+		if errs[i].Line > 0 {
+			continue
+		}
+		if node := nodeAtPath(root, errs[i].Path); node != nil {
+			errs[i].Line = node.Line
+			errs[i].Column = node.Column
+		}
+	}
+}
+
+// Sort orders findings by their position in the source document. Findings without a
+// position keep their relative order and are placed last.
+func (errs ValidationErrors) Sort() {
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i], errs[j]
+		if (a.Line == 0) != (b.Line == 0) {
+			return a.Line != 0
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+}
+
+// validationCollector accumulates findings while walking a configuration
+type validationCollector struct {
+	errs ValidationErrors
+}
+
+// add records a finding with error severity
+func (vc *validationCollector) add(rule, path, format string, args ...interface{}) {
+	vc.errs = append(vc.errs, ValidationError{
+		Severity: SeverityError,
+		Rule:     rule,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// warn records a finding with warning severity
+func (vc *validationCollector) warn(rule, path, format string, args ...interface{}) {
+	vc.errs = append(vc.errs, ValidationError{
+		Severity: SeverityWarning,
+		Rule:     rule,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// info records a finding with info severity
+func (vc *validationCollector) info(rule, path, format string, args ...interface{}) {
+	vc.errs = append(vc.errs, ValidationError{
+		Severity: SeverityInfo,
+		Rule:     rule,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// result returns the collected findings as an error, or nil if there are none
+func (vc *validationCollector) result() error {
+	if len(vc.errs) == 0 {
+		return nil
+	}
+	return vc.errs
+}
+
+// pathKey appends a mapping key to a path. Keys that would make the path ambiguous
+// (e.g. board labels such as "REF-SMA2/U.FL2") are quoted in brackets.
+func pathKey(path, key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\" ") {
+		return fmt.Sprintf("%s[%q]", path, key)
+	}
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// pathIndex appends a sequence index to a path
+func pathIndex(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
+
+// nodeAtPath follows a path produced by pathKey and pathIndex through a YAML node tree
+// and returns the deepest node it could reach
+func nodeAtPath(root *yaml.Node, path string) *yaml.Node {
+	node := root
+	if node.Kind == yaml.DocumentNode {
+		if len(node.Content) == 0 {
+			return nil
+		}
+		node = node.Content[0]
+	}
+
+	for _, elem := range splitPath(path) {
+		for node.Kind == yaml.AliasNode {
+			node = node.Alias
+		}
+		var next *yaml.Node
+		switch node.Kind {
+		case yaml.MappingNode:
+			if elem.isIndex {
+				return node
+			}
+			next = mappingValue(node, elem.key)
+		case yaml.SequenceNode:
+			if elem.isIndex && elem.index < len(node.Content) {
+				next = node.Content[elem.index]
+			}
+		}
+		if next == nil {
+			return node
+		}
+		node = next
+	}
+	return node
+}
+
+// cloneNode deep-copies a YAML node tree. Alias nodes of the copy refer to the copied anchors.
+func cloneNode(node *yaml.Node, clones map[*yaml.Node]*yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	if clone, ok := clones[node]; ok {
+		return clone
+	}
+	clone := *node
+	clones[node] = &clone
+	clone.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		clone.Content[i] = cloneNode(child, clones)
+	}
+	clone.Alias = cloneNode(node.Alias, clones)
+	return &clone
+}
+
+// pathElem is a single step of a validation path
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// splitPath parses a path such as structure[1].dpll.phaseOutputs["U.FL2"] into its steps
+func splitPath(path string) []pathElem {
+	var elems []pathElem
+	for i := 0; i < len(path); {
+		switch path[i] {
+		case '.':
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return elems
+			}
+			inner := path[i+1 : i+end]
+			if strings.HasPrefix(inner, "\"") {
+				// Quoted keys may contain ']' themselves, so find the closing quote first
+				if key, rest, ok := unquotePrefix(path[i+1:]); ok && strings.HasPrefix(rest, "]") {
+					elems = append(elems, pathElem{key: key})
+					i = len(path) - len(rest) + 1
+					continue
+				}
+				return elems
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil {
+				return elems
+			}
+			elems = append(elems, pathElem{index: index, isIndex: true})
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			elems = append(elems, pathElem{key: path[i : i+end]})
+			i += end
+		}
+	}
+	return elems
+}
+
+// unquotePrefix unquotes the Go-quoted string at the start of s and returns the remainder
+func unquotePrefix(s string) (string, string, bool) {
+	prefix, err := strconv.QuotedPrefix(s)
+	if err != nil {
+		return "", s, false
+	}
+	value, err := strconv.Unquote(prefix)
+	if err != nil {
+		return "", s, false
+	}
+	return value, s[len(prefix):], true
+}